@@ -1,30 +1,138 @@
 package main
 
 import (
+	"crypto/subtle"
+	"github.com/devtron-labs/chart-sync/internals"
+	"github.com/devtron-labs/chart-sync/internals/logger"
 	"github.com/devtron-labs/chart-sync/pkg"
+	"github.com/devtron-labs/chart-sync/pkg/provider"
+	"github.com/devtron-labs/chart-sync/pkg/secret"
 	"github.com/go-pg/pg"
 	"go.uber.org/zap"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type App struct {
-	Logger      *zap.SugaredLogger
-	db          *pg.DB
-	syncService pkg.SyncService
+	Logger        *zap.SugaredLogger
+	db            *pg.DB
+	syncService   pkg.SyncService
+	configuration *internals.Configuration
+
+	credentialEncryptionService secret.CredentialEncryptionService
+	logLevels                   *logger.LogLevels
+	providerService             provider.ProviderService
 }
 
 func NewApp(Logger *zap.SugaredLogger,
 	db *pg.DB,
-	syncService pkg.SyncService) *App {
+	syncService pkg.SyncService,
+	configuration *internals.Configuration,
+	credentialEncryptionService secret.CredentialEncryptionService,
+	logLevels *logger.LogLevels,
+	providerService provider.ProviderService) *App {
 	return &App{
-		Logger:      Logger,
-		db:          db,
-		syncService: syncService,
+		Logger:        Logger,
+		db:            db,
+		syncService:   syncService,
+		configuration: configuration,
+
+		credentialEncryptionService: credentialEncryptionService,
+		logLevels:                   logLevels,
+		providerService:             providerService,
 	}
 }
 
-func (app *App) Start() {
-	_, err := app.syncService.Sync()
+// Start writes the providers of the providers file into the tables in upsert mode and syncs, failing before any sync
+// when they can not be written
+func (app *App) Start() error {
+	err := app.providerService.UpsertProviders()
+	if err != nil {
+		app.Logger.Errorw("error in upserting providers", "err", err)
+		return err
+	}
+	if app.configuration.DaemonMode {
+		app.startDaemon()
+		return nil
+	}
+	app.sync()
+	return nil
+}
+
+// startDaemon serves the admin endpoints and syncs every SYNC_INTERVAL_IN_MINUTES, keeping registry credentials cached
+// in memory between syncs
+func (app *App) startDaemon() {
+	go app.startAdminServer()
+	for {
+		app.sync()
+		app.Logger.Infow("waiting for next sync", "intervalInMinutes", app.configuration.SyncIntervalInMinutes)
+		time.Sleep(time.Duration(app.configuration.SyncIntervalInMinutes) * time.Minute)
+	}
+}
+
+func (app *App) sync() {
+	report, err := app.syncService.Sync()
 	if err != nil {
 		app.Logger.Errorw("err", "err", err)
 	}
+	if report != nil {
+		for _, certificate := range report.ExpiringCertificates {
+			app.Logger.Warnw("certificate of registry is expiring", "registryName", certificate.DockerArtifactStoreId,
+				"certificateType", certificate.CertificateType, "notAfter", certificate.NotAfter, "isExpired", certificate.IsExpired)
+		}
+	}
+}
+
+// ReEncryptCredentials encrypts the credentials stored in the chart provider tables with the configured key encryption key
+func (app *App) ReEncryptCredentials(options secret.ReEncryptOptions) error {
+	err := app.credentialEncryptionService.ReEncryptCredentials(options)
+	if err != nil {
+		app.Logger.Errorw("error in encrypting credentials", "err", err)
+	}
+	return err
+}
+
+// startAdminServer serves the admin endpoints of daemon mode, like changing log levels at runtime with
+// curl -X PUT localhost:8080/admin/log/level -d '{"level":"debug","component":"registry"}'
+func (app *App) startAdminServer() {
+	adminHost := app.configuration.AdminHost
+	if len(app.configuration.AdminToken) == 0 && !isLoopbackHost(adminHost) {
+		app.Logger.Errorw("not starting admin server, ADMIN_TOKEN is required to listen on other interfaces than the loopback one", "host", adminHost)
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/admin/log/level", app.logLevels)
+	var handler http.Handler = mux
+	if len(app.configuration.AdminToken) > 0 {
+		handler = requireToken(app.configuration.AdminToken, mux)
+	}
+	address := net.JoinHostPort(adminHost, strconv.Itoa(app.configuration.AdminPort))
+	app.Logger.Infow("starting admin server", "address", address)
+	err := http.ListenAndServe(address, handler)
+	if err != nil {
+		app.Logger.Errorw("error in starting admin server", "err", err)
+	}
+}
+
+// requireToken rejects requests which do not send the token as Authorization: Bearer <token>
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestToken, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(requestToken), []byte(token)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
 }
@@ -7,6 +7,43 @@ type Configuration struct {
 	ChartProviderId                  string `env:"CHART_PROVIDER_ID" envDefault:"*"` // * is used to sync all chart providers; else CHART_PROVIDER_ID should contain chart_repo_id OR docker_artifact_store_id
 	IsOCIRegistry                    bool   `env:"IS_OCI_REGISTRY" envDefault:"true"`
 	ParallelismLimitForTagProcessing int    `env:"PARALLELISM_LIMIT_FOR_TAG_PROCESSING" envDefault:"0"`
+	OCITagListPageSize               int    `env:"OCI_TAG_LIST_PAGE_SIZE" envDefault:"1000"`
+	// 0 lists all tags of an OCI repository on every run, otherwise runs in between only list the tags sorting lexically
+	// after the last tag listed, which misses tags like 1.10.0 pushed after 1.9.0 until the next full list
+	OCIFullTagListIntervalInHours int `env:"OCI_FULL_TAG_LIST_INTERVAL_IN_HOURS" envDefault:"0"`
+	// host keys ssh servers of remote connections are verified against, ssh tunnels are refused without any
+	SSHKnownHostsFile      string `env:"SSH_KNOWN_HOSTS_FILE" envDefault:""`
+	SSHHostKeyFingerprints string `env:"SSH_HOST_KEY_FINGERPRINTS" envDefault:""` // like bastion.example.com=SHA256:<base64>,[bastion.example.com]:2222=SHA256:<base64>
+	DaemonMode             bool   `env:"DAEMON_MODE" envDefault:"false"`          // keeps syncing every SYNC_INTERVAL_IN_MINUTES instead of exiting after one sync
+	SyncIntervalInMinutes  int    `env:"SYNC_INTERVAL_IN_MINUTES" envDefault:"60"`
+	AdminPort              int    `env:"ADMIN_PORT" envDefault:"8080"` // port of the admin endpoints like /admin/log/level in daemon mode
+	// the admin endpoints only listen on the loopback interface unless ADMIN_TOKEN is set, which requests then have to
+	// send as Authorization: Bearer <token>
+	AdminHost  string `env:"ADMIN_HOST" envDefault:"127.0.0.1"`
+	AdminToken string `env:"ADMIN_TOKEN" envDefault:""`
+	// registry tokens like the ones of ECR are fetched again when they expire within this window
+	RegistryCredentialRefreshBeforeInMinutes int    `env:"REGISTRY_CREDENTIAL_REFRESH_BEFORE_IN_MINUTES" envDefault:"30"`
+	RegistryCredentialCacheFile              string `env:"REGISTRY_CREDENTIAL_CACHE_FILE" envDefault:""`       // registry tokens are cached in this file across runs when set
+	RegistryCredentialCacheKey               string `env:"REGISTRY_CREDENTIAL_CACHE_KEY" envDefault:""`        // base64 encoded 32 byte AES key the cache file is encrypted with
+	CertificateExpiryWarningInDays           int    `env:"CERTIFICATE_EXPIRY_WARNING_IN_DAYS" envDefault:"30"` // certificates expiring within this period are reported in the sync report
+	CredentialKeyEncryptionKeyFile           string `env:"CREDENTIAL_KEK_FILE" envDefault:""`                  // file with the base64 encoded 32 byte key wrapping the data keys of enc:// credentials
+	CredentialKeyEncryptionKeyPlugin         string `env:"CREDENTIAL_KEK_PLUGIN" envDefault:""`                // executable wrapping the data keys instead, like a KMS client; takes precedence over the file
+	CredentialPreviousKeyEncryptionKeyFile   string `env:"CREDENTIAL_PREVIOUS_KEK_FILE" envDefault:""`         // file with the key encryption key being rotated out, only used to unwrap data keys the current one does not
+	// file://, env:// and k8s:// credentials only resolve to files in these comma separated directories, environment
+	// variables with this prefix and Secrets in these comma separated namespaces, none resolve by default
+	SecretReferenceFileDirs      string `env:"SECRET_REFERENCE_FILE_DIRS" envDefault:""`
+	SecretReferenceEnvPrefix     string `env:"SECRET_REFERENCE_ENV_PREFIX" envDefault:""`
+	SecretReferenceK8sNamespaces string `env:"SECRET_REFERENCE_K8S_NAMESPACES" envDefault:""`
+	// yaml file declaring chart repos and registries, see provider.ProvidersFile
+	ProvidersFile     string `env:"PROVIDERS_FILE" envDefault:""`
+	ProvidersFileMode string `env:"PROVIDERS_FILE_MODE" envDefault:"merge"` // merge, upsert or direct, see provider.ProvidersFileMode
+	// secret references of the providers file are rejected in upsert mode, unless their resolved secrets may be written
+	// into the tables the orchestrator reads in plaintext
+	ProvidersFileUpsertResolvedSecrets bool `env:"PROVIDERS_FILE_UPSERT_RESOLVED_SECRETS" envDefault:"false"`
+	// postgres stores chart providers and app stores in the orchestrator database, file in json files under STORAGE_DIR
+	// for running chart-sync without the orchestrator, like locally or in CI
+	StorageBackend string `env:"STORAGE_BACKEND" envDefault:"postgres"`
+	StorageDir     string `env:"STORAGE_DIR" envDefault:"chart-sync-data"`
 }
 
 func ParseConfiguration() (*Configuration, error) {
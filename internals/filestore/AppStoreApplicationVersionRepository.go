@@ -0,0 +1,220 @@
+package filestore
+
+import (
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"github.com/go-pg/pg"
+	"strings"
+)
+
+type AppStoreApplicationVersionRepositoryImpl struct {
+	fileStore *FileStore
+}
+
+func NewAppStoreApplicationVersionRepositoryImpl(fileStore *FileStore) *AppStoreApplicationVersionRepositoryImpl {
+	return &AppStoreApplicationVersionRepositoryImpl{fileStore: fileStore}
+}
+
+// copyVersion returns a copy of the row and its maintainers and dependencies, without its app store
+func copyVersion(version *sql.AppStoreApplicationVersion) *sql.AppStoreApplicationVersion {
+	versionCopy := *version
+	versionCopy.AppStore = nil
+	versionCopy.Maintainers = nil
+	for _, maintainer := range version.Maintainers {
+		maintainerCopy := *maintainer
+		versionCopy.Maintainers = append(versionCopy.Maintainers, &maintainerCopy)
+	}
+	versionCopy.Dependencies = nil
+	for _, dependency := range version.Dependencies {
+		dependencyCopy := *dependency
+		versionCopy.Dependencies = append(versionCopy.Dependencies, &dependencyCopy)
+	}
+	return &versionCopy
+}
+
+// FindVersionsByAppStoreId returns the versions with the columns the database repository selects
+func (impl *AppStoreApplicationVersionRepositoryImpl) FindVersionsByAppStoreId(appStoreId int) ([]*sql.AppStoreApplicationVersion, error) {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	var versions []*sql.AppStoreApplicationVersion
+	for _, version := range impl.fileStore.versions[appStoreId] {
+		versions = append(versions, &sql.AppStoreApplicationVersion{
+			Id:         version.Id,
+			Version:    version.Version,
+			Name:       version.Name,
+			Created:    version.Created,
+			Deprecated: version.Deprecated,
+			Digest:     version.Digest,
+		})
+	}
+	return versions, nil
+}
+
+func (impl *AppStoreApplicationVersionRepositoryImpl) FindVersionsWithoutCreatedOrChartYaml(appStoreId int) ([]*sql.AppStoreApplicationVersion, error) {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	var versions []*sql.AppStoreApplicationVersion
+	for _, version := range impl.fileStore.versions[appStoreId] {
+		if version.Created.IsZero() || !strings.HasPrefix(strings.TrimSpace(version.ChartYaml), "{") {
+			versions = append(versions, &sql.AppStoreApplicationVersion{
+				Id:         version.Id,
+				Version:    version.Version,
+				Created:    version.Created,
+				Deprecated: version.Deprecated,
+				ChartYaml:  version.ChartYaml,
+				AuditLog:   sql.AuditLog{CreatedOn: version.CreatedOn},
+			})
+		}
+	}
+	return versions, nil
+}
+
+func (impl *AppStoreApplicationVersionRepositoryImpl) FindVersionsWithoutChartType(appStoreId int) ([]*sql.AppStoreApplicationVersion, error) {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	var versions []*sql.AppStoreApplicationVersion
+	for _, version := range impl.fileStore.versions[appStoreId] {
+		if len(version.ChartType) == 0 {
+			versions = append(versions, &sql.AppStoreApplicationVersion{
+				Id:        version.Id,
+				Version:   version.Version,
+				ChartYaml: version.ChartYaml,
+			})
+		}
+	}
+	return versions, nil
+}
+
+// Save inserts the versions which are not stored yet and sets the ids of all of them, existing versions are kept as
+// they are apart from their maintainers and dependencies, like the insert on conflict do nothing of the database. No
+// version is changed when writing the files of their app stores fails.
+func (impl *AppStoreApplicationVersionRepositoryImpl) Save(versions *[]*sql.AppStoreApplicationVersion) error {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	appStoreIds := make(map[int]bool)
+	for _, version := range *versions {
+		appStoreIds[version.AppStoreId] = true
+	}
+	storedVersions := impl.fileStore.copyVersions(appStoreIds)
+	insertedVersionAppStoreIds := make(map[int]int)
+	for _, version := range *versions {
+		storedVersion := findRow(storedVersions[version.AppStoreId], func(storedVersion *sql.AppStoreApplicationVersion) bool {
+			return storedVersion.Version == version.Version
+		})
+		if storedVersion == nil {
+			version.Id = impl.fileStore.nextId(tableAppStoreApplicationVersion)
+			storedVersion = copyVersion(version)
+			storedVersions[version.AppStoreId] = append(storedVersions[version.AppStoreId], storedVersion)
+			insertedVersionAppStoreIds[version.Id] = version.AppStoreId
+		}
+		version.Id = storedVersion.Id
+		impl.saveVersionMetadata(storedVersion, version)
+	}
+	err := impl.fileStore.swapVersions(storedVersions)
+	if err != nil {
+		return err
+	}
+	for versionId, appStoreId := range insertedVersionAppStoreIds {
+		impl.fileStore.versionAppStoreId[versionId] = appStoreId
+	}
+	return nil
+}
+
+func (impl *AppStoreApplicationVersionRepositoryImpl) FindOneByAppStoreIdAndVersion(appStoreId int, version string) (*sql.AppStoreApplicationVersion, error) {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	storedVersion := impl.findByAppStoreIdAndVersion(appStoreId, version)
+	if storedVersion == nil {
+		return &sql.AppStoreApplicationVersion{}, pg.ErrNoRows
+	}
+	versionCopy := copyVersion(storedVersion)
+	// maintainers and dependencies are not selected with the version from the database
+	versionCopy.Maintainers = nil
+	versionCopy.Dependencies = nil
+	return versionCopy, nil
+}
+
+// Update updates the non zero columns of the versions, none of them are updated when any of them does not exist or
+// writing the files of their app stores fails
+func (impl *AppStoreApplicationVersionRepositoryImpl) Update(appVersions []*sql.AppStoreApplicationVersion) error {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	versionsByAppStoreId, storedVersions, err := impl.copyByIds(appVersions)
+	if err != nil {
+		return err
+	}
+	for i, version := range appVersions {
+		updateNotNull(storedVersions[i], version)
+	}
+	return impl.fileStore.swapVersions(versionsByAppStoreId)
+}
+
+// UpdateMetadata updates the structured metadata columns of the versions and replaces their maintainers and
+// dependencies, none of them are updated when any of them does not exist or writing the files of their app stores fails
+func (impl *AppStoreApplicationVersionRepositoryImpl) UpdateMetadata(appVersions []*sql.AppStoreApplicationVersion) error {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	versionsByAppStoreId, storedVersions, err := impl.copyByIds(appVersions)
+	if err != nil {
+		return err
+	}
+	for i, version := range appVersions {
+		storedVersion := storedVersions[i]
+		storedVersion.Source = version.Source
+		storedVersion.Keywords = version.Keywords
+		storedVersion.KubeVersion = version.KubeVersion
+		storedVersion.ChartType = version.ChartType
+		storedVersion.UpdatedOn = version.UpdatedOn
+		impl.saveVersionMetadata(storedVersion, version)
+	}
+	return impl.fileStore.swapVersions(versionsByAppStoreId)
+}
+
+// saveVersionMetadata replaces the maintainers and dependencies of the stored version with the ones of the version,
+// the caller holds the mutex
+func (impl *AppStoreApplicationVersionRepositoryImpl) saveVersionMetadata(storedVersion, version *sql.AppStoreApplicationVersion) {
+	storedVersion.Maintainers = nil
+	for _, maintainer := range version.Maintainers {
+		maintainer.Id = impl.fileStore.nextId(tableMaintainer)
+		maintainer.AppStoreApplicationVersionId = storedVersion.Id
+		maintainerCopy := *maintainer
+		storedVersion.Maintainers = append(storedVersion.Maintainers, &maintainerCopy)
+	}
+	storedVersion.Dependencies = nil
+	for _, dependency := range version.Dependencies {
+		dependency.Id = impl.fileStore.nextId(tableDependency)
+		dependency.AppStoreApplicationVersionId = storedVersion.Id
+		dependencyCopy := *dependency
+		storedVersion.Dependencies = append(storedVersion.Dependencies, &dependencyCopy)
+	}
+}
+
+// findByAppStoreIdAndVersion returns the stored row, the caller holds the mutex
+func (impl *AppStoreApplicationVersionRepositoryImpl) findByAppStoreIdAndVersion(appStoreId int, version string) *sql.AppStoreApplicationVersion {
+	for _, storedVersion := range impl.fileStore.versions[appStoreId] {
+		if storedVersion.Version == version {
+			return storedVersion
+		}
+	}
+	return nil
+}
+
+// copyByIds returns copies of the stored versions of the app stores the versions belong to, along with the copies of
+// the rows of the versions among them, none when any of the versions does not exist. The caller holds the mutex.
+func (impl *AppStoreApplicationVersionRepositoryImpl) copyByIds(appVersions []*sql.AppStoreApplicationVersion) (map[int][]*sql.AppStoreApplicationVersion, []*sql.AppStoreApplicationVersion, error) {
+	appStoreIds := make(map[int]bool)
+	for _, version := range appVersions {
+		appStoreIds[impl.fileStore.versionAppStoreId[version.Id]] = true
+	}
+	versionsByAppStoreId := impl.fileStore.copyVersions(appStoreIds)
+	storedVersions := make([]*sql.AppStoreApplicationVersion, 0, len(appVersions))
+	for _, version := range appVersions {
+		storedVersion := findRow(versionsByAppStoreId[impl.fileStore.versionAppStoreId[version.Id]], func(storedVersion *sql.AppStoreApplicationVersion) bool {
+			return storedVersion.Id == version.Id
+		})
+		if storedVersion == nil {
+			return nil, nil, pg.ErrNoRows
+		}
+		storedVersions = append(storedVersions, storedVersion)
+	}
+	return versionsByAppStoreId, storedVersions, nil
+}
@@ -0,0 +1,96 @@
+package filestore
+
+import (
+	"fmt"
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"github.com/go-pg/pg"
+)
+
+type AppStoreRepositoryImpl struct {
+	fileStore *FileStore
+}
+
+func NewAppStoreRepositoryImpl(fileStore *FileStore) *AppStoreRepositoryImpl {
+	return &AppStoreRepositoryImpl{fileStore: fileStore}
+}
+
+// copyAppStore returns a copy of the row without its relations
+func copyAppStore(appStore *sql.AppStore) *sql.AppStore {
+	appStoreCopy := *appStore
+	appStoreCopy.ChartRepo = sql.ChartRepo{}
+	return &appStoreCopy
+}
+
+func (impl *AppStoreRepositoryImpl) FindByRepoId(repoId int) ([]*sql.AppStore, error) {
+	return impl.find(func(appStore *sql.AppStore) bool {
+		return appStore.ChartRepoId == repoId && appStore.Active
+	}), nil
+}
+
+func (impl *AppStoreRepositoryImpl) FindByStoreId(storeId string) ([]*sql.AppStore, error) {
+	return impl.find(func(appStore *sql.AppStore) bool {
+		return appStore.DockerArtifactStoreId == storeId && appStore.Active
+	}), nil
+}
+
+func (impl *AppStoreRepositoryImpl) FindInactiveOneByRepositoryPath(storeId, repositoryPath string) (*sql.AppStore, error) {
+	appStores := impl.find(func(appStore *sql.AppStore) bool {
+		return appStore.DockerArtifactStoreId == storeId && !appStore.Active &&
+			(appStore.RepositoryPath == repositoryPath || (len(appStore.RepositoryPath) == 0 && appStore.Name == repositoryPath))
+	})
+	if len(appStores) == 0 {
+		return &sql.AppStore{}, pg.ErrNoRows
+	}
+	return appStores[0], nil
+}
+
+func (impl *AppStoreRepositoryImpl) Save(appStore *sql.AppStore) error {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	if appStore.Id == 0 {
+		appStore.Id = impl.fileStore.nextId(tableAppStore)
+	} else if impl.findById(appStore.Id) != nil {
+		return fmt.Errorf("app store %d already exists", appStore.Id)
+	} else {
+		impl.fileStore.setLastId(tableAppStore, appStore.Id)
+	}
+	return impl.fileStore.swapAppStores(append(copyRows(impl.fileStore.appStores), copyAppStore(appStore)))
+}
+
+// Update updates the non zero columns of the app stores, none of them are updated when any of them does not exist or
+// writing the file fails
+func (impl *AppStoreRepositoryImpl) Update(appStores []*sql.AppStore) error {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	storedAppStores := copyRows(impl.fileStore.appStores)
+	for _, appStore := range appStores {
+		storedAppStore := findRow(storedAppStores, func(storedAppStore *sql.AppStore) bool { return storedAppStore.Id == appStore.Id })
+		if storedAppStore == nil {
+			return pg.ErrNoRows
+		}
+		updateNotNull(storedAppStore, appStore)
+	}
+	return impl.fileStore.swapAppStores(storedAppStores)
+}
+
+// findById returns the stored row, the caller holds the mutex
+func (impl *AppStoreRepositoryImpl) findById(id int) *sql.AppStore {
+	for _, appStore := range impl.fileStore.appStores {
+		if appStore.Id == id {
+			return appStore
+		}
+	}
+	return nil
+}
+
+func (impl *AppStoreRepositoryImpl) find(matches func(appStore *sql.AppStore) bool) []*sql.AppStore {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	var appStores []*sql.AppStore
+	for _, appStore := range impl.fileStore.appStores {
+		if matches(appStore) {
+			appStores = append(appStores, copyAppStore(appStore))
+		}
+	}
+	return appStores
+}
@@ -0,0 +1,85 @@
+package filestore
+
+import (
+	"fmt"
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"github.com/go-pg/pg"
+)
+
+type ChartRepoRepositoryImpl struct {
+	fileStore *FileStore
+}
+
+func NewChartRepoRepositoryImpl(fileStore *FileStore) *ChartRepoRepositoryImpl {
+	return &ChartRepoRepositoryImpl{fileStore: fileStore}
+}
+
+// copyChartRepo returns a copy of the row without its relations
+func copyChartRepo(chartRepo *sql.ChartRepo) *sql.ChartRepo {
+	chartRepoCopy := *chartRepo
+	chartRepoCopy.RemoteConnectionConfig = nil
+	return &chartRepoCopy
+}
+
+func (impl *ChartRepoRepositoryImpl) Save(chartRepo *sql.ChartRepo) error {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	if chartRepo.Id == 0 {
+		chartRepo.Id = impl.fileStore.nextId(tableChartRepo)
+	} else if impl.findById(chartRepo.Id) != nil {
+		return fmt.Errorf("chart repo %d already exists", chartRepo.Id)
+	} else {
+		impl.fileStore.setLastId(tableChartRepo, chartRepo.Id)
+	}
+	providers := impl.fileStore.copyProviders()
+	providers.ChartRepos = append(providers.ChartRepos, copyChartRepo(chartRepo))
+	return impl.fileStore.swapProviders(providers)
+}
+
+func (impl *ChartRepoRepositoryImpl) GetDefault() (*sql.ChartRepo, error) {
+	return impl.findOne(func(chartRepo *sql.ChartRepo) bool {
+		return chartRepo.Default && !chartRepo.Deleted && chartRepo.Active
+	})
+}
+
+func (impl *ChartRepoRepositoryImpl) FindById(id int) (*sql.ChartRepo, error) {
+	return impl.findOne(func(chartRepo *sql.ChartRepo) bool {
+		return chartRepo.Id == id && !chartRepo.Deleted
+	})
+}
+
+func (impl *ChartRepoRepositoryImpl) GetAll() ([]*sql.ChartRepo, error) {
+	return impl.find(func(chartRepo *sql.ChartRepo) bool {
+		return chartRepo.External && !chartRepo.Deleted
+	}), nil
+}
+
+// findById returns the stored row, the caller holds the mutex
+func (impl *ChartRepoRepositoryImpl) findById(id int) *sql.ChartRepo {
+	for _, chartRepo := range impl.fileStore.providers.ChartRepos {
+		if chartRepo.Id == id {
+			return chartRepo
+		}
+	}
+	return nil
+}
+
+func (impl *ChartRepoRepositoryImpl) find(matches func(chartRepo *sql.ChartRepo) bool) []*sql.ChartRepo {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	var chartRepos []*sql.ChartRepo
+	for _, chartRepo := range impl.fileStore.providers.ChartRepos {
+		if matches(chartRepo) {
+			chartRepos = append(chartRepos, copyChartRepo(chartRepo))
+		}
+	}
+	return chartRepos
+}
+
+func (impl *ChartRepoRepositoryImpl) findOne(matches func(chartRepo *sql.ChartRepo) bool) (*sql.ChartRepo, error) {
+	chartRepos := impl.find(matches)
+	if len(chartRepos) == 0 {
+		return &sql.ChartRepo{}, pg.ErrNoRows
+	}
+	return chartRepos[0], nil
+}
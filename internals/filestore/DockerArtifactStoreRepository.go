@@ -0,0 +1,75 @@
+package filestore
+
+import (
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"github.com/go-pg/pg"
+)
+
+type DockerArtifactStoreRepositoryImpl struct {
+	fileStore *FileStore
+}
+
+func NewDockerArtifactStoreRepositoryImpl(fileStore *FileStore) *DockerArtifactStoreRepositoryImpl {
+	return &DockerArtifactStoreRepositoryImpl{fileStore: fileStore}
+}
+
+// copyDockerArtifactStore returns a copy of the row without its relations
+func copyDockerArtifactStore(store *sql.DockerArtifactStore) *sql.DockerArtifactStore {
+	storeCopy := *store
+	storeCopy.OCIRegistryConfig = nil
+	storeCopy.RemoteConnectionConfig = nil
+	return &storeCopy
+}
+
+func (impl *DockerArtifactStoreRepositoryImpl) FindAllChartProviders() ([]*sql.DockerArtifactStore, error) {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	var providers []*sql.DockerArtifactStore
+	for _, store := range impl.fileStore.providers.DockerArtifactStores {
+		if store.Active {
+			providers = append(providers, impl.withChartProviderRelations(store))
+		}
+	}
+	return providers, nil
+}
+
+func (impl *DockerArtifactStoreRepositoryImpl) FindOne(storeId string) (*sql.DockerArtifactStore, error) {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	store := impl.findById(storeId)
+	if store == nil || !store.Active {
+		return &sql.DockerArtifactStore{}, pg.ErrNoRows
+	}
+	return impl.withChartProviderRelations(store), nil
+}
+
+// findById returns the stored row, the caller holds the mutex
+func (impl *DockerArtifactStoreRepositoryImpl) findById(storeId string) *sql.DockerArtifactStore {
+	for _, store := range impl.fileStore.providers.DockerArtifactStores {
+		if store.Id == storeId {
+			return store
+		}
+	}
+	return nil
+}
+
+// withChartProviderRelations returns a copy of the store with its active chart pull configs and its remote connection,
+// like the relations the database query selects. The caller holds the mutex.
+func (impl *DockerArtifactStoreRepositoryImpl) withChartProviderRelations(store *sql.DockerArtifactStore) *sql.DockerArtifactStore {
+	storeCopy := copyDockerArtifactStore(store)
+	for _, ociRegistryConfig := range impl.fileStore.providers.OCIRegistryConfigs {
+		if ociRegistryConfig.DockerArtifactStoreId == store.Id && !ociRegistryConfig.Deleted && ociRegistryConfig.IsChartPullActive &&
+			ociRegistryConfig.RepositoryType == sql.OCI_REGISRTY_REPO_TYPE_CHART &&
+			(ociRegistryConfig.RepositoryAction == sql.STORAGE_ACTION_TYPE_PULL || ociRegistryConfig.RepositoryAction == sql.STORAGE_ACTION_TYPE_PULL_AND_PUSH) {
+			ociRegistryConfigCopy := *ociRegistryConfig
+			storeCopy.OCIRegistryConfig = append(storeCopy.OCIRegistryConfig, &ociRegistryConfigCopy)
+		}
+	}
+	for _, remoteConnectionConfig := range impl.fileStore.providers.RemoteConnectionConfigs {
+		if store.RemoteConnectionConfigId > 0 && remoteConnectionConfig.Id == store.RemoteConnectionConfigId {
+			remoteConnectionConfigCopy := *remoteConnectionConfig
+			storeCopy.RemoteConnectionConfig = &remoteConnectionConfigCopy
+		}
+	}
+	return storeCopy
+}
@@ -0,0 +1,267 @@
+package filestore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	logger2 "github.com/devtron-labs/chart-sync/internals/logger"
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	providersFileName = "providers.json"
+	appStoresFileName = "app_stores.json"
+	versionsDirName   = "versions" // holds a file of versions per app store, so that saving versions only writes one small file
+)
+
+// tables the ids of rows are generated for
+const (
+	tableChartRepo                  = "chart_repo"
+	tableOCIRegistryConfig          = "oci_registry_config"
+	tableRemoteConnectionConfig     = "remote_connection_config"
+	tableAppStore                   = "app_store"
+	tableAppStoreApplicationVersion = "app_store_application_version"
+	tableMaintainer                 = "app_store_application_version_maintainer"
+	tableDependency                 = "app_store_application_version_dependency"
+)
+
+type providerTables struct {
+	ChartRepos              []*sql.ChartRepo              `json:"chartRepos"`
+	DockerArtifactStores    []*sql.DockerArtifactStore    `json:"dockerArtifactStores"`
+	OCIRegistryConfigs      []*sql.OCIRegistryConfig      `json:"ociRegistryConfigs"`
+	RemoteConnectionConfigs []*sql.RemoteConnectionConfig `json:"remoteConnectionConfigs"`
+}
+
+// FileStore holds the rows of the tables chart-sync uses in memory and persists them as json files in a directory, so
+// that chart-sync can run without the orchestrator database. Repositories of the store return copies of the rows like
+// the ones reading from the database, changes are only stored through their save and update methods.
+type FileStore struct {
+	logger    *zap.SugaredLogger
+	dir       string
+	providers *providerTables
+	appStores []*sql.AppStore
+	// versions by app store id, and the app store id of each version id
+	versions          map[int][]*sql.AppStoreApplicationVersion
+	versionAppStoreId map[int]int
+	lastIds           map[string]int
+	mutex             sync.Mutex
+}
+
+func NewFileStore(logger *zap.SugaredLogger, dir string) (*FileStore, error) {
+	impl := &FileStore{
+		logger:            logger.Named(logger2.ComponentSql),
+		dir:               dir,
+		providers:         &providerTables{},
+		versions:          make(map[int][]*sql.AppStoreApplicationVersion),
+		versionAppStoreId: make(map[int]int),
+		lastIds:           make(map[string]int),
+	}
+	err := os.MkdirAll(filepath.Join(dir, versionsDirName), 0700)
+	if err != nil {
+		impl.logger.Errorw("error in creating storage dir", "dir", dir, "err", err)
+		return nil, err
+	}
+	err = impl.load()
+	if err != nil {
+		impl.logger.Errorw("error in loading storage dir", "dir", dir, "err", err)
+		return nil, err
+	}
+	impl.logger.Infow("loaded storage dir", "dir", dir, "chartRepos", len(impl.providers.ChartRepos),
+		"dockerArtifactStores", len(impl.providers.DockerArtifactStores), "appStores", len(impl.appStores))
+	return impl, nil
+}
+
+func (impl *FileStore) load() error {
+	err := readJsonFile(filepath.Join(impl.dir, providersFileName), impl.providers)
+	if err != nil {
+		return err
+	}
+	err = readJsonFile(filepath.Join(impl.dir, appStoresFileName), &impl.appStores)
+	if err != nil {
+		return err
+	}
+	versionFiles, err := os.ReadDir(filepath.Join(impl.dir, versionsDirName))
+	if err != nil {
+		return err
+	}
+	for _, versionFile := range versionFiles {
+		appStoreId, err := strconv.Atoi(strings.TrimSuffix(versionFile.Name(), ".json"))
+		if err != nil || versionFile.IsDir() {
+			continue
+		}
+		var versions []*sql.AppStoreApplicationVersion
+		err = readJsonFile(filepath.Join(impl.dir, versionsDirName, versionFile.Name()), &versions)
+		if err != nil {
+			return err
+		}
+		impl.versions[appStoreId] = versions
+		for _, version := range versions {
+			impl.versionAppStoreId[version.Id] = appStoreId
+			impl.setLastId(tableAppStoreApplicationVersion, version.Id)
+			for _, maintainer := range version.Maintainers {
+				impl.setLastId(tableMaintainer, maintainer.Id)
+			}
+			for _, dependency := range version.Dependencies {
+				impl.setLastId(tableDependency, dependency.Id)
+			}
+		}
+	}
+	for _, chartRepo := range impl.providers.ChartRepos {
+		impl.setLastId(tableChartRepo, chartRepo.Id)
+	}
+	for _, ociRegistryConfig := range impl.providers.OCIRegistryConfigs {
+		impl.setLastId(tableOCIRegistryConfig, ociRegistryConfig.Id)
+	}
+	for _, remoteConnectionConfig := range impl.providers.RemoteConnectionConfigs {
+		impl.setLastId(tableRemoteConnectionConfig, remoteConnectionConfig.Id)
+	}
+	for _, appStore := range impl.appStores {
+		impl.setLastId(tableAppStore, appStore.Id)
+	}
+	return nil
+}
+
+func (impl *FileStore) setLastId(table string, id int) {
+	if id > impl.lastIds[table] {
+		impl.lastIds[table] = id
+	}
+}
+
+// nextId returns the next id of the table like a serial column, the caller holds the mutex
+func (impl *FileStore) nextId(table string) int {
+	impl.lastIds[table]++
+	return impl.lastIds[table]
+}
+
+func (impl *FileStore) saveProviders() error {
+	return writeJsonFile(filepath.Join(impl.dir, providersFileName), impl.providers)
+}
+
+func (impl *FileStore) saveAppStores() error {
+	return writeJsonFile(filepath.Join(impl.dir, appStoresFileName), impl.appStores)
+}
+
+func (impl *FileStore) saveVersions(appStoreId int) error {
+	return writeJsonFile(filepath.Join(impl.dir, versionsDirName, fmt.Sprintf("%d.json", appStoreId)), impl.versions[appStoreId])
+}
+
+// copyProviders returns copies of the stored provider rows, which are changed and kept with swapProviders. The caller
+// holds the mutex.
+func (impl *FileStore) copyProviders() *providerTables {
+	return &providerTables{
+		ChartRepos:              copyRows(impl.providers.ChartRepos),
+		DockerArtifactStores:    copyRows(impl.providers.DockerArtifactStores),
+		OCIRegistryConfigs:      copyRows(impl.providers.OCIRegistryConfigs),
+		RemoteConnectionConfigs: copyRows(impl.providers.RemoteConnectionConfigs),
+	}
+}
+
+// swapProviders keeps the changed provider rows once the providers file is written, so that no row is changed when
+// writing it fails. The caller holds the mutex.
+func (impl *FileStore) swapProviders(providers *providerTables) error {
+	storedProviders := impl.providers
+	impl.providers = providers
+	if err := impl.saveProviders(); err != nil {
+		impl.providers = storedProviders
+		return err
+	}
+	return nil
+}
+
+// swapAppStores keeps the changed app stores once the app stores file is written, so that no app store is changed when
+// writing it fails. The caller holds the mutex.
+func (impl *FileStore) swapAppStores(appStores []*sql.AppStore) error {
+	storedAppStores := impl.appStores
+	impl.appStores = appStores
+	if err := impl.saveAppStores(); err != nil {
+		impl.appStores = storedAppStores
+		return err
+	}
+	return nil
+}
+
+// copyVersions returns copies of the stored versions of the app stores, which are changed and kept with swapVersions.
+// The caller holds the mutex.
+func (impl *FileStore) copyVersions(appStoreIds map[int]bool) map[int][]*sql.AppStoreApplicationVersion {
+	versions := make(map[int][]*sql.AppStoreApplicationVersion, len(appStoreIds))
+	for appStoreId := range appStoreIds {
+		appStoreVersions := make([]*sql.AppStoreApplicationVersion, 0, len(impl.versions[appStoreId]))
+		for _, version := range impl.versions[appStoreId] {
+			appStoreVersions = append(appStoreVersions, copyVersion(version))
+		}
+		versions[appStoreId] = appStoreVersions
+	}
+	return versions
+}
+
+// swapVersions keeps the changed versions of the app stores once their files are written. When writing a file fails,
+// the stored versions are kept and the files written before are written again with them, so that no version is
+// changed. The caller holds the mutex.
+func (impl *FileStore) swapVersions(versions map[int][]*sql.AppStoreApplicationVersion) error {
+	storedVersions := make(map[int][]*sql.AppStoreApplicationVersion, len(versions))
+	for appStoreId, appStoreVersions := range versions {
+		storedVersions[appStoreId] = impl.versions[appStoreId]
+		impl.versions[appStoreId] = appStoreVersions
+	}
+	writtenAppStoreIds := make([]int, 0, len(versions))
+	for appStoreId := range versions {
+		err := impl.saveVersions(appStoreId)
+		if err == nil {
+			writtenAppStoreIds = append(writtenAppStoreIds, appStoreId)
+			continue
+		}
+		for storedAppStoreId, appStoreVersions := range storedVersions {
+			impl.versions[storedAppStoreId] = appStoreVersions
+		}
+		for _, writtenAppStoreId := range writtenAppStoreIds {
+			if restoreErr := impl.saveVersions(writtenAppStoreId); restoreErr != nil {
+				impl.logger.Errorw("error in restoring versions file", "appStoreId", writtenAppStoreId, "err", restoreErr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// readJsonFile decodes the file into value, leaving value as it is when the file does not exist yet
+func readJsonFile(path string, value interface{}) error {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	err = json.Unmarshal(content, value)
+	if err != nil {
+		return fmt.Errorf("invalid storage file %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeJsonFile writes a temp file first and renames it, so that an interrupted write never leaves a truncated file
+// behind. Files are only readable by the owner as they hold credentials like the database does.
+func writeJsonFile(path string, value interface{}) error {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.Write(content)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), path)
+}
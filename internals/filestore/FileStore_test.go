@@ -0,0 +1,217 @@
+package filestore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"go.uber.org/zap"
+)
+
+func newTestFileStore(t *testing.T, dir string) *FileStore {
+	t.Helper()
+	fileStore, err := NewFileStore(zap.NewNop().Sugar(), dir)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	return fileStore
+}
+
+func newTestVersion(appStoreId int, version string) *sql.AppStoreApplicationVersion {
+	return &sql.AppStoreApplicationVersion{
+		AppStoreId:   appStoreId,
+		Version:      version,
+		Name:         "redis",
+		ValuesYaml:   "{}",
+		Maintainers:  []*sql.AppStoreApplicationVersionMaintainer{{Name: "maintainer"}},
+		Dependencies: []*sql.AppStoreApplicationVersionDependency{{Name: "common", Version: "2.x.x"}},
+	}
+}
+
+func TestFileStore_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	fileStore := newTestFileStore(t, dir)
+	chartRepoRepository := NewChartRepoRepositoryImpl(fileStore)
+	appStoreRepository := NewAppStoreRepositoryImpl(fileStore)
+	versionRepository := NewAppStoreApplicationVersionRepositoryImpl(fileStore)
+
+	chartRepo := &sql.ChartRepo{Name: "charts", Url: "https://charts.example.com", External: true, Active: true, Password: "password"}
+	if err := chartRepoRepository.Save(chartRepo); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	redis := &sql.AppStore{Name: "redis", ChartRepoId: chartRepo.Id, Active: true}
+	nginx := &sql.AppStore{Name: "nginx", ChartRepoId: chartRepo.Id, Active: true}
+	for _, appStore := range []*sql.AppStore{redis, nginx} {
+		if err := appStoreRepository.Save(appStore); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	versions := []*sql.AppStoreApplicationVersion{newTestVersion(redis.Id, "1.0.0"), newTestVersion(redis.Id, "1.1.0"), newTestVersion(nginx.Id, "2.0.0")}
+	if err := versionRepository.Save(&versions); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	// saving a stored version again keeps it and replaces its maintainers
+	again := []*sql.AppStoreApplicationVersion{newTestVersion(redis.Id, "1.0.0")}
+	again[0].ValuesYaml = "changed"
+	if err := versionRepository.Save(&again); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if again[0].Id != versions[0].Id {
+		t.Errorf("Save() set id %d on a stored version, want %d", again[0].Id, versions[0].Id)
+	}
+	redis.ChartName = "redis-chart"
+	if err := appStoreRepository.Update([]*sql.AppStore{redis}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := versionRepository.Update([]*sql.AppStoreApplicationVersion{{Id: versions[1].Id, Deprecated: true}}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := versionRepository.UpdateMetadata([]*sql.AppStoreApplicationVersion{{Id: versions[2].Id, ChartType: "application", Keywords: "web"}}); err != nil {
+		t.Fatalf("UpdateMetadata() error = %v", err)
+	}
+
+	reloaded := newTestFileStore(t, dir)
+	reloadedChartRepo, err := NewChartRepoRepositoryImpl(reloaded).FindById(chartRepo.Id)
+	if err != nil || reloadedChartRepo.Password != "password" || reloadedChartRepo.Url != chartRepo.Url {
+		t.Errorf("FindById() = %+v, %v, want the saved chart repo", reloadedChartRepo, err)
+	}
+	reloadedAppStores, _ := NewAppStoreRepositoryImpl(reloaded).FindByRepoId(chartRepo.Id)
+	if len(reloadedAppStores) != 2 || reloadedAppStores[0].ChartName != "redis-chart" {
+		t.Errorf("FindByRepoId() = %+v, want both app stores with the updated chart name", reloadedAppStores)
+	}
+	reloadedVersionRepository := NewAppStoreApplicationVersionRepositoryImpl(reloaded)
+	redisVersions, _ := reloadedVersionRepository.FindVersionsByAppStoreId(redis.Id)
+	if len(redisVersions) != 2 || redisVersions[0].Deprecated || !redisVersions[1].Deprecated {
+		t.Errorf("FindVersionsByAppStoreId() = %+v, want 1.0.0 and the deprecated 1.1.0", redisVersions)
+	}
+	redisVersion, err := reloadedVersionRepository.FindOneByAppStoreIdAndVersion(redis.Id, "1.0.0")
+	if err != nil || redisVersion.ValuesYaml != "{}" {
+		t.Errorf("FindOneByAppStoreIdAndVersion() = %+v, %v, want the version as it was first saved", redisVersion, err)
+	}
+	if withoutChartType, _ := reloadedVersionRepository.FindVersionsWithoutChartType(nginx.Id); len(withoutChartType) != 0 {
+		t.Errorf("FindVersionsWithoutChartType() = %+v, want none after UpdateMetadata", withoutChartType)
+	}
+
+	// ids continue after the largest stored ones across restarts
+	lastMaintainerId := reloaded.lastIds[tableMaintainer]
+	if lastMaintainerId < 4 {
+		t.Errorf("last maintainer id = %d, want at least 4 after saving maintainers of 4 versions", lastMaintainerId)
+	}
+	chartRepo2 := &sql.ChartRepo{Name: "charts-2", Url: "https://charts-2.example.com"}
+	if err = NewChartRepoRepositoryImpl(reloaded).Save(chartRepo2); err != nil || chartRepo2.Id != chartRepo.Id+1 {
+		t.Errorf("Save() id = %d, %v, want %d", chartRepo2.Id, err, chartRepo.Id+1)
+	}
+	postgres := &sql.AppStore{Name: "postgres", Active: true}
+	if err = NewAppStoreRepositoryImpl(reloaded).Save(postgres); err != nil || postgres.Id != nginx.Id+1 {
+		t.Errorf("Save() id = %d, %v, want %d", postgres.Id, err, nginx.Id+1)
+	}
+	newVersions := []*sql.AppStoreApplicationVersion{newTestVersion(postgres.Id, "3.0.0")}
+	if err = reloadedVersionRepository.Save(&newVersions); err != nil || newVersions[0].Id != versions[2].Id+1 {
+		t.Errorf("Save() id = %d, %v, want %d", newVersions[0].Id, err, versions[2].Id+1)
+	}
+	if maintainerId := newVersions[0].Maintainers[0].Id; maintainerId != lastMaintainerId+1 {
+		t.Errorf("Save() maintainer id = %d, want %d", maintainerId, lastMaintainerId+1)
+	}
+	if err = reloadedVersionRepository.Update([]*sql.AppStoreApplicationVersion{{Id: newVersions[0].Id, Deprecated: true}}); err != nil {
+		t.Errorf("Update() error = %v, want the version saved after the restart to be found", err)
+	}
+}
+
+func TestFileStore_RollbackOnWriteFailure(t *testing.T) {
+	dir := t.TempDir()
+	fileStore := newTestFileStore(t, dir)
+	appStoreRepository := NewAppStoreRepositoryImpl(fileStore)
+	versionRepository := NewAppStoreApplicationVersionRepositoryImpl(fileStore)
+	providerRepository := NewProviderRepositoryImpl(fileStore)
+	chartRepo := &sql.ChartRepo{Name: "charts", Url: "https://charts.example.com", External: true, Password: "password"}
+	if err := NewChartRepoRepositoryImpl(fileStore).Save(chartRepo); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	redis := &sql.AppStore{Name: "redis", Active: true}
+	nginx := &sql.AppStore{Name: "nginx", Active: true}
+	for _, appStore := range []*sql.AppStore{redis, nginx} {
+		if err := appStoreRepository.Save(appStore); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	versions := []*sql.AppStoreApplicationVersion{newTestVersion(redis.Id, "1.0.0"), newTestVersion(nginx.Id, "2.0.0")}
+	if err := versionRepository.Save(&versions); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	redisVersionsFile := filepath.Join(dir, versionsDirName, "1.json")
+	redisVersionsBefore, err := os.ReadFile(redisVersionsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// renaming the written temp file onto a directory fails, even for root
+	nginxVersionsFile := filepath.Join(dir, versionsDirName, "2.json")
+	if err = os.Remove(nginxVersionsFile); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Mkdir(nginxVersionsFile, 0700); err != nil {
+		t.Fatal(err)
+	}
+	newVersions := []*sql.AppStoreApplicationVersion{newTestVersion(redis.Id, "1.1.0"), newTestVersion(nginx.Id, "2.1.0")}
+	if err = versionRepository.Save(&newVersions); err == nil {
+		t.Fatal("Save() expected error when a versions file can not be written")
+	}
+	for _, appStoreId := range []int{redis.Id, nginx.Id} {
+		if storedVersions, _ := versionRepository.FindVersionsByAppStoreId(appStoreId); len(storedVersions) != 1 {
+			t.Errorf("FindVersionsByAppStoreId(%d) = %d versions, want the saved version only", appStoreId, len(storedVersions))
+		}
+	}
+	if redisVersionsAfter, _ := os.ReadFile(redisVersionsFile); string(redisVersionsAfter) != string(redisVersionsBefore) {
+		t.Error("Save() left the versions file of another app store changed")
+	}
+	deprecated := []*sql.AppStoreApplicationVersion{{Id: versions[0].Id, Deprecated: true}, {Id: versions[1].Id, Deprecated: true}}
+	if err = versionRepository.Update(deprecated); err == nil {
+		t.Fatal("Update() expected error when a versions file can not be written")
+	}
+	if err = versionRepository.UpdateMetadata([]*sql.AppStoreApplicationVersion{{Id: versions[1].Id, ChartType: "application"}}); err == nil {
+		t.Fatal("UpdateMetadata() expected error when a versions file can not be written")
+	}
+	if storedVersions, _ := versionRepository.FindVersionsByAppStoreId(redis.Id); storedVersions[0].Deprecated {
+		t.Error("Update() deprecated a version although writing failed")
+	}
+	if withoutChartType, _ := versionRepository.FindVersionsWithoutChartType(nginx.Id); len(withoutChartType) != 1 {
+		t.Error("UpdateMetadata() changed a version although writing failed")
+	}
+
+	// the app stores and providers files can not be written once the storage dir is gone
+	if err = os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err = appStoreRepository.Save(&sql.AppStore{Name: "postgres", Active: true}); err == nil {
+		t.Fatal("Save() expected error when the app stores file can not be written")
+	}
+	if err = appStoreRepository.Update([]*sql.AppStore{{Id: redis.Id, ChartName: "changed"}}); err == nil {
+		t.Fatal("Update() expected error when the app stores file can not be written")
+	}
+	if appStores, _ := appStoreRepository.FindByStoreId(""); len(appStores) != 2 || appStores[0].ChartName == "changed" {
+		t.Errorf("FindByStoreId() = %+v, want the app stores as they were", appStores)
+	}
+	if err = providerRepository.UpdateCredentials([]*sql.ChartRepo{{Id: chartRepo.Id, Password: "changed"}}, nil, nil); err == nil {
+		t.Fatal("UpdateCredentials() expected error when the providers file can not be written")
+	}
+	if storedChartRepo, _ := NewChartRepoRepositoryImpl(fileStore).FindById(chartRepo.Id); storedChartRepo.Password != "password" {
+		t.Errorf("FindById() password = %q, want the credentials as they were", storedChartRepo.Password)
+	}
+}
+
+func TestFileStore_UpdateCredentialsOfMissingRow(t *testing.T) {
+	fileStore := newTestFileStore(t, t.TempDir())
+	chartRepo := &sql.ChartRepo{Name: "charts", Url: "https://charts.example.com", Password: "password"}
+	if err := NewChartRepoRepositoryImpl(fileStore).Save(chartRepo); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	err := NewProviderRepositoryImpl(fileStore).UpdateCredentials([]*sql.ChartRepo{{Id: chartRepo.Id, Password: "changed"}},
+		[]*sql.DockerArtifactStore{{Id: "missing", Password: "changed"}}, nil)
+	if err == nil {
+		t.Fatal("UpdateCredentials() expected error for a missing registry")
+	}
+	if storedChartRepo, _ := NewChartRepoRepositoryImpl(fileStore).FindById(chartRepo.Id); storedChartRepo.Password != "password" {
+		t.Errorf("FindById() password = %q, want the credentials as they were", storedChartRepo.Password)
+	}
+}
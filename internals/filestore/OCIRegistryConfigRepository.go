@@ -0,0 +1,37 @@
+package filestore
+
+import (
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"github.com/go-pg/pg"
+)
+
+type OCIRegistryConfigRepositoryImpl struct {
+	fileStore *FileStore
+}
+
+func NewOCIRegistryConfigRepositoryImpl(fileStore *FileStore) *OCIRegistryConfigRepositoryImpl {
+	return &OCIRegistryConfigRepositoryImpl{fileStore: fileStore}
+}
+
+func (impl *OCIRegistryConfigRepositoryImpl) FindByDockerRegistryId(dockerRegistryId string) ([]*sql.OCIRegistryConfig, error) {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	var ociRegistryConfigs []*sql.OCIRegistryConfig
+	for _, ociRegistryConfig := range impl.fileStore.providers.OCIRegistryConfigs {
+		if ociRegistryConfig.DockerArtifactStoreId == dockerRegistryId && !ociRegistryConfig.Deleted {
+			ociRegistryConfigCopy := *ociRegistryConfig
+			ociRegistryConfigs = append(ociRegistryConfigs, &ociRegistryConfigCopy)
+		}
+	}
+	return ociRegistryConfigs, nil
+}
+
+func (impl *OCIRegistryConfigRepositoryImpl) FindOneByDockerRegistryIdAndRepositoryType(dockerRegistryId string, repositoryType string) (*sql.OCIRegistryConfig, error) {
+	ociRegistryConfigs, _ := impl.FindByDockerRegistryId(dockerRegistryId)
+	for _, ociRegistryConfig := range ociRegistryConfigs {
+		if ociRegistryConfig.RepositoryType == repositoryType {
+			return ociRegistryConfig, nil
+		}
+	}
+	return &sql.OCIRegistryConfig{}, pg.ErrNoRows
+}
@@ -0,0 +1,157 @@
+package filestore
+
+import (
+	"fmt"
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"github.com/go-pg/pg"
+)
+
+type ProviderRepositoryImpl struct {
+	fileStore *FileStore
+}
+
+func NewProviderRepositoryImpl(fileStore *FileStore) *ProviderRepositoryImpl {
+	return &ProviderRepositoryImpl{fileStore: fileStore}
+}
+
+// UpdateCredentials updates the credentials of the rows with one write of the providers file, none of them are updated
+// when any of them does not exist or writing the file fails
+func (impl *ProviderRepositoryImpl) UpdateCredentials(chartRepos []*sql.ChartRepo, stores []*sql.DockerArtifactStore, remoteConnectionConfigs []*sql.RemoteConnectionConfig) error {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	providers := impl.fileStore.copyProviders()
+	for _, chartRepo := range chartRepos {
+		storedChartRepo := findRow(providers.ChartRepos, func(storedChartRepo *sql.ChartRepo) bool { return storedChartRepo.Id == chartRepo.Id })
+		if storedChartRepo == nil {
+			return pg.ErrNoRows
+		}
+		storedChartRepo.Password = chartRepo.Password
+		storedChartRepo.AccessToken = chartRepo.AccessToken
+		storedChartRepo.CustomHeaders = chartRepo.CustomHeaders
+	}
+	for _, store := range stores {
+		storedStore := findRow(providers.DockerArtifactStores, func(storedStore *sql.DockerArtifactStore) bool { return storedStore.Id == store.Id })
+		if storedStore == nil {
+			return pg.ErrNoRows
+		}
+		storedStore.Password = store.Password
+		storedStore.AWSSecretAccessKey = store.AWSSecretAccessKey
+		storedStore.ClientKey = store.ClientKey
+	}
+	for _, remoteConnectionConfig := range remoteConnectionConfigs {
+		storedRemoteConnectionConfig := findRow(providers.RemoteConnectionConfigs, func(storedRemoteConnectionConfig *sql.RemoteConnectionConfig) bool {
+			return storedRemoteConnectionConfig.Id == remoteConnectionConfig.Id
+		})
+		if storedRemoteConnectionConfig == nil {
+			return pg.ErrNoRows
+		}
+		storedRemoteConnectionConfig.SSHPassword = remoteConnectionConfig.SSHPassword
+		storedRemoteConnectionConfig.SSHAuthKey = remoteConnectionConfig.SSHAuthKey
+	}
+	return impl.fileStore.swapProviders(providers)
+}
+
+// UpsertProviders changes copies of the stored rows and only keeps them once the providers file is written, so that
+// no row is changed when writing it fails
+func (impl *ProviderRepositoryImpl) UpsertProviders(chartRepos []*sql.ChartRepo, stores []*sql.DockerArtifactStore) error {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	providers := impl.fileStore.copyProviders()
+	for _, chartRepo := range chartRepos {
+		existingChartRepo := findRow(providers.ChartRepos, func(storedChartRepo *sql.ChartRepo) bool {
+			return storedChartRepo.Name == chartRepo.Name && !storedChartRepo.Deleted
+		})
+		if existingChartRepo != nil {
+			chartRepo.Id = existingChartRepo.Id
+			chartRepo.Default = existingChartRepo.Default
+			chartRepo.RemoteConnectionConfigId = existingChartRepo.RemoteConnectionConfigId
+			chartRepo.CreatedOn = existingChartRepo.CreatedOn
+			chartRepo.CreatedBy = existingChartRepo.CreatedBy
+			*existingChartRepo = *copyChartRepo(chartRepo)
+			continue
+		}
+		if chartRepo.Id == 0 {
+			chartRepo.Id = impl.fileStore.nextId(tableChartRepo)
+		} else if findRow(providers.ChartRepos, func(storedChartRepo *sql.ChartRepo) bool { return storedChartRepo.Id == chartRepo.Id }) != nil {
+			return fmt.Errorf("chart repo %d already exists", chartRepo.Id)
+		} else {
+			impl.fileStore.setLastId(tableChartRepo, chartRepo.Id)
+		}
+		providers.ChartRepos = append(providers.ChartRepos, copyChartRepo(chartRepo))
+	}
+	for _, store := range stores {
+		storedStore := findRow(providers.DockerArtifactStores, func(storedStore *sql.DockerArtifactStore) bool { return storedStore.Id == store.Id })
+		if storedStore == nil {
+			providers.DockerArtifactStores = append(providers.DockerArtifactStores, copyDockerArtifactStore(store))
+		} else {
+			// same columns as the upsert of the database, keeping the default flag, remote connection and creation audit
+			storedStore.PluginId = store.PluginId
+			storedStore.RegistryURL = store.RegistryURL
+			storedStore.RegistryType = store.RegistryType
+			storedStore.IsOCICompliantRegistry = store.IsOCICompliantRegistry
+			storedStore.AWSAccessKeyId = store.AWSAccessKeyId
+			storedStore.AWSSecretAccessKey = store.AWSSecretAccessKey
+			storedStore.AWSRegion = store.AWSRegion
+			storedStore.Username = store.Username
+			storedStore.Password = store.Password
+			storedStore.Connection = store.Connection
+			storedStore.Cert = store.Cert
+			storedStore.ClientCert = store.ClientCert
+			storedStore.ClientKey = store.ClientKey
+			storedStore.Active = store.Active
+			storedStore.UpdatedOn = store.UpdatedOn
+			storedStore.UpdatedBy = store.UpdatedBy
+		}
+		impl.upsertChartPullConfigs(providers, store)
+	}
+	return impl.fileStore.swapProviders(providers)
+}
+
+// upsertChartPullConfigs matches the chart pull configs of the store with the stored ones by their position, like the
+// database does by their id, the caller holds the mutex
+func (impl *ProviderRepositoryImpl) upsertChartPullConfigs(providers *providerTables, store *sql.DockerArtifactStore) {
+	var existingConfigs []*sql.OCIRegistryConfig
+	for _, ociRegistryConfig := range providers.OCIRegistryConfigs {
+		if ociRegistryConfig.DockerArtifactStoreId == store.Id && ociRegistryConfig.RepositoryType == sql.OCI_REGISRTY_REPO_TYPE_CHART &&
+			!ociRegistryConfig.Deleted {
+			existingConfigs = append(existingConfigs, ociRegistryConfig)
+		}
+	}
+	for i, ociRegistryConfig := range store.OCIRegistryConfig {
+		if i < len(existingConfigs) {
+			ociRegistryConfig.Id = existingConfigs[i].Id
+			ociRegistryConfig.CreatedOn = existingConfigs[i].CreatedOn
+			ociRegistryConfig.CreatedBy = existingConfigs[i].CreatedBy
+			*existingConfigs[i] = *ociRegistryConfig
+		} else {
+			ociRegistryConfig.Id = impl.fileStore.nextId(tableOCIRegistryConfig)
+			ociRegistryConfigCopy := *ociRegistryConfig
+			providers.OCIRegistryConfigs = append(providers.OCIRegistryConfigs, &ociRegistryConfigCopy)
+		}
+	}
+	for i := len(store.OCIRegistryConfig); i < len(existingConfigs); i++ {
+		existingConfigs[i].Deleted = true
+		existingConfigs[i].UpdatedOn = store.UpdatedOn
+		existingConfigs[i].UpdatedBy = store.UpdatedBy
+	}
+}
+
+// copyRows returns copies of the stored rows, the caller holds the mutex
+func copyRows[T any](rows []*T) []*T {
+	rowCopies := make([]*T, 0, len(rows))
+	for _, row := range rows {
+		rowCopy := *row
+		rowCopies = append(rowCopies, &rowCopy)
+	}
+	return rowCopies
+}
+
+// findRow returns the first stored row matching, the caller holds the mutex
+func findRow[T any](rows []*T, matches func(row *T) bool) *T {
+	for _, row := range rows {
+		if matches(row) {
+			return row
+		}
+	}
+	return nil
+}
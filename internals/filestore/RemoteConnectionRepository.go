@@ -0,0 +1,26 @@
+package filestore
+
+import (
+	"github.com/devtron-labs/chart-sync/internals/sql"
+)
+
+type RemoteConnectionRepositoryImpl struct {
+	fileStore *FileStore
+}
+
+func NewRemoteConnectionRepositoryImpl(fileStore *FileStore) *RemoteConnectionRepositoryImpl {
+	return &RemoteConnectionRepositoryImpl{fileStore: fileStore}
+}
+
+// GetById returns an empty config when there is none with the id, like the database repository does
+func (impl *RemoteConnectionRepositoryImpl) GetById(id int) (*sql.RemoteConnectionConfig, error) {
+	impl.fileStore.mutex.Lock()
+	defer impl.fileStore.mutex.Unlock()
+	for _, remoteConnectionConfig := range impl.fileStore.providers.RemoteConnectionConfigs {
+		if remoteConnectionConfig.Id == id && !remoteConnectionConfig.Deleted {
+			remoteConnectionConfigCopy := *remoteConnectionConfig
+			return &remoteConnectionConfigCopy, nil
+		}
+	}
+	return &sql.RemoteConnectionConfig{}, nil
+}
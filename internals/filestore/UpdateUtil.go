@@ -0,0 +1,66 @@
+package filestore
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// updateNotNull copies the column fields of src to dst like UpdateNotNull of go-pg does: the primary key, relations and
+// fields tagged sql:"-" are skipped, and so are zero values unless the column is tagged notnull
+func updateNotNull(dst, src interface{}) {
+	copyNotNullFields(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem())
+}
+
+func copyNotNullFields(dst, src reflect.Value) {
+	valueType := src.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			// embedded structs like AuditLog hold columns of the table
+			copyNotNullFields(dst.Field(i), src.Field(i))
+			continue
+		}
+		tag := field.Tag.Get("sql")
+		if tag == "-" || isRelation(field.Type) {
+			continue
+		}
+		_, options, _ := strings.Cut(tag, ",")
+		if hasOption(options, "pk") {
+			continue
+		}
+		if src.Field(i).IsZero() && !hasOption(options, "notnull") {
+			continue
+		}
+		dst.Field(i).Set(src.Field(i))
+	}
+}
+
+func hasOption(options string, option string) bool {
+	for _, tagOption := range strings.Split(options, ",") {
+		if tagOption == option {
+			return true
+		}
+	}
+	return false
+}
+
+// isRelation reports whether a field of the type holds related models, like the ChartRepo of an AppStore, instead of
+// a column
+func isRelation(fieldType reflect.Type) bool {
+	switch fieldType.Kind() {
+	case reflect.Struct:
+		return fieldType != timeType
+	case reflect.Ptr:
+		return fieldType.Elem().Kind() == reflect.Struct && fieldType.Elem() != timeType
+	case reflect.Slice:
+		return fieldType.Elem().Kind() == reflect.Ptr || fieldType.Elem().Kind() == reflect.Struct
+	default:
+		return false
+	}
+}
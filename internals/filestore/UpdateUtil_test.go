@@ -0,0 +1,90 @@
+package filestore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devtron-labs/chart-sync/internals/sql"
+)
+
+func TestUpdateNotNull(t *testing.T) {
+	createdOn := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	updatedOn := createdOn.Add(time.Hour)
+	stored := func() *sql.AppStore {
+		return &sql.AppStore{
+			Id: 1, Name: "redis", RepositoryPath: "library/redis", ChartName: "redis", Active: true,
+			TagListCursor: "1.0.0", CreatedOn: createdOn, UpdatedOn: createdOn, ChartRepo: sql.ChartRepo{Id: 3, Name: "charts"},
+		}
+	}
+	tests := []struct {
+		name  string
+		src   *sql.AppStore
+		check func(t *testing.T, dst *sql.AppStore)
+	}{
+		{
+			name: "non zero columns are copied",
+			src:  &sql.AppStore{Id: 1, ChartName: "redis-chart", TagListCursor: "2.0.0", UpdatedOn: updatedOn, Active: true, Name: "redis"},
+			check: func(t *testing.T, dst *sql.AppStore) {
+				if dst.ChartName != "redis-chart" || dst.TagListCursor != "2.0.0" || !dst.UpdatedOn.Equal(updatedOn) {
+					t.Errorf("updateNotNull() = %+v, want the non zero columns copied", dst)
+				}
+			},
+		},
+		{
+			name: "zero columns are skipped unless notnull",
+			src:  &sql.AppStore{Id: 1},
+			check: func(t *testing.T, dst *sql.AppStore) {
+				if dst.RepositoryPath != "library/redis" || dst.TagListCursor != "1.0.0" {
+					t.Errorf("updateNotNull() = %+v, want zero columns skipped", dst)
+				}
+				// notnull columns are copied even when zero, like go-pg does
+				if dst.Active || len(dst.Name) > 0 || !dst.CreatedOn.IsZero() {
+					t.Errorf("updateNotNull() = %+v, want notnull columns copied", dst)
+				}
+			},
+		},
+		{
+			name: "primary key and relations are skipped",
+			src:  &sql.AppStore{Id: 2, Name: "redis", Active: true, CreatedOn: createdOn, UpdatedOn: createdOn, ChartRepo: sql.ChartRepo{Id: 4, Name: "other"}},
+			check: func(t *testing.T, dst *sql.AppStore) {
+				if dst.Id != 1 || dst.ChartRepo.Id != 3 || dst.ChartRepo.Name != "charts" {
+					t.Errorf("updateNotNull() = %+v, want the primary key and the relation kept", dst)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := stored()
+			updateNotNull(dst, tt.src)
+			tt.check(t, dst)
+		})
+	}
+}
+
+func TestUpdateNotNull_EmbeddedAuditLogAndRelations(t *testing.T) {
+	createdOn := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	updatedOn := createdOn.Add(time.Hour)
+	appStore := &sql.AppStore{Id: 1}
+	dst := &sql.AppStoreApplicationVersion{
+		Id: 1, Version: "1.0.0", ValuesYaml: "{}", AppStore: appStore,
+		AuditLog:    sql.AuditLog{CreatedOn: createdOn, CreatedBy: 2, UpdatedOn: createdOn, UpdatedBy: 2},
+		Maintainers: []*sql.AppStoreApplicationVersionMaintainer{{Id: 1, Name: "maintainer"}},
+	}
+	updateNotNull(dst, &sql.AppStoreApplicationVersion{
+		Id: 2, Deprecated: true, AuditLog: sql.AuditLog{UpdatedOn: updatedOn, UpdatedBy: 1},
+		AppStore: &sql.AppStore{Id: 2}, Maintainers: []*sql.AppStoreApplicationVersionMaintainer{},
+	})
+	if !dst.UpdatedOn.Equal(updatedOn) || dst.UpdatedBy != 1 {
+		t.Errorf("updateNotNull() audit log = %+v, want the non zero fields of the embedded audit log copied", dst.AuditLog)
+	}
+	if !dst.CreatedOn.Equal(createdOn) || dst.CreatedBy != 2 {
+		t.Errorf("updateNotNull() audit log = %+v, want the zero fields of the embedded audit log skipped", dst.AuditLog)
+	}
+	if dst.Id != 1 || dst.Version != "1.0.0" || dst.ValuesYaml != "{}" || !dst.Deprecated {
+		t.Errorf("updateNotNull() = %+v, want the deprecated flag copied only", dst)
+	}
+	if dst.AppStore != appStore || len(dst.Maintainers) != 1 {
+		t.Errorf("updateNotNull() relations = %+v, %+v, want the relations kept", dst.AppStore, dst.Maintainers)
+	}
+}
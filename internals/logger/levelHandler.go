@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap/zapcore"
+	"net/http"
+)
+
+type levelRequest struct {
+	Level     string `json:"level"`
+	Component string `json:"component,omitempty"` // all components when empty
+}
+
+type levelResponse struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+// ServeHTTP returns the levels on GET and changes them on PUT with a body like {"level":"debug","component":"registry"}.
+// Without a component the default level and the levels of all components are changed.
+func (impl *LogLevels) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var request levelRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			impl.writeError(w, fmt.Errorf("invalid request: %w", err))
+			return
+		}
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(request.Level)); err != nil {
+			impl.writeError(w, err)
+			return
+		}
+		if len(request.Component) > 0 {
+			componentLevel, ok := impl.componentLevels[request.Component]
+			if !ok {
+				impl.writeError(w, fmt.Errorf("unknown component %s", request.Component))
+				return
+			}
+			componentLevel.SetLevel(level)
+		} else {
+			impl.defaultLevel.SetLevel(level)
+			for _, componentLevel := range impl.componentLevels {
+				componentLevel.SetLevel(level)
+			}
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	response := levelResponse{
+		Level:      impl.defaultLevel.String(),
+		Components: make(map[string]string),
+	}
+	for component, componentLevel := range impl.componentLevels {
+		response.Components[component] = componentLevel.String()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (impl *LogLevels) writeError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -1,17 +1,140 @@
 package logger
 
-import "go.uber.org/zap"
+import (
+	"fmt"
+	"github.com/caarlos0/env"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"os"
+	"strings"
+	"time"
+)
 
-func NewSugardLogger() *zap.SugaredLogger {
-	l, err := zap.NewProduction()
-	if err != nil {
-		panic("failed to create the default logger: " + err.Error())
+// components the loggers of chart-sync are named after, each having its own level
+const (
+	ComponentSync     = "sync"
+	ComponentHelm     = "helm"
+	ComponentSql      = "sql"
+	ComponentRegistry = "registry"
+)
+
+var components = []string{ComponentSync, ComponentHelm, ComponentSql, ComponentRegistry}
+
+type LogConfig struct {
+	Level              string `env:"LOG_LEVEL" envDefault:"info"`
+	Encoding           string `env:"LOG_ENCODING" envDefault:"json"` // json or console
+	SamplingEnabled    bool   `env:"LOG_SAMPLING_ENABLED" envDefault:"true"`
+	SamplingInitial    int    `env:"LOG_SAMPLING_INITIAL" envDefault:"100"`    // entries with the same level and message logged each second before sampling
+	SamplingThereafter int    `env:"LOG_SAMPLING_THEREAFTER" envDefault:"100"` // every nth entry logged after that within the second
+	// levels of components overriding LOG_LEVEL, like sync=debug,registry=debug
+	ComponentLevels string `env:"LOG_COMPONENT_LEVELS" envDefault:""`
+}
+
+func GetLogConfig() (*LogConfig, error) {
+	cfg := &LogConfig{}
+	err := env.Parse(cfg)
+	return cfg, err
+}
+
+// LogLevels holds the levels of the components, which can be changed at runtime
+type LogLevels struct {
+	defaultLevel    zap.AtomicLevel
+	componentLevels map[string]zap.AtomicLevel
+}
+
+func NewLogLevels(cfg *LogConfig) (*LogLevels, error) {
+	levels := &LogLevels{
+		defaultLevel:    zap.NewAtomicLevel(),
+		componentLevels: make(map[string]zap.AtomicLevel),
+	}
+	if err := levels.defaultLevel.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("invalid LOG_LEVEL %s: %w", cfg.Level, err)
+	}
+	for _, component := range components {
+		levels.componentLevels[component] = zap.NewAtomicLevelAt(levels.defaultLevel.Level())
+	}
+	for _, componentLevel := range strings.Split(cfg.ComponentLevels, ",") {
+		if len(strings.TrimSpace(componentLevel)) == 0 {
+			continue
+		}
+		component, level, _ := strings.Cut(strings.TrimSpace(componentLevel), "=")
+		atomicLevel, ok := levels.componentLevels[component]
+		if !ok {
+			return nil, fmt.Errorf("invalid LOG_COMPONENT_LEVELS, unknown component %s", component)
+		}
+		if err := atomicLevel.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("invalid LOG_COMPONENT_LEVELS, level of component %s: %w", component, err)
+		}
+	}
+	return levels, nil
+}
+
+// levelOf returns the level of the component of the logger name, the default level for loggers of no component
+func (impl *LogLevels) levelOf(loggerName string) zap.AtomicLevel {
+	component, _, _ := strings.Cut(loggerName, ".")
+	if level, ok := impl.componentLevels[component]; ok {
+		return level
+	}
+	return impl.defaultLevel
+}
+
+// Enabled reports whether the level is enabled for any component
+func (impl *LogLevels) Enabled(level zapcore.Level) bool {
+	if impl.defaultLevel.Enabled(level) {
+		return true
+	}
+	for _, componentLevel := range impl.componentLevels {
+		if componentLevel.Enabled(level) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSugardLogger returns the root logger, components log through loggers named after them, like logger.Named(ComponentSync)
+func NewSugardLogger(cfg *LogConfig, levels *LogLevels) (*zap.SugaredLogger, error) {
+	encoderConfig := zap.NewProductionEncoderConfig()
+	var encoder zapcore.Encoder
+	switch cfg.Encoding {
+	case "json":
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case "console":
+		encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, fmt.Errorf("invalid LOG_ENCODING %s, expected json or console", cfg.Encoding)
 	}
-	//l, err := zap.NewDevelopment()
-	/*l, err := zap.NewProduction()
-	if err != nil {
-		panic("failed to create the default logger: " + err.Error())
+	// levels are checked by componentLevelCore, the wrapped core logs everything passed to it
+	var core zapcore.Core = zapcore.NewCore(encoder, zapcore.Lock(os.Stderr), zapcore.DebugLevel)
+	if cfg.SamplingEnabled {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, cfg.SamplingInitial, cfg.SamplingThereafter)
+	}
+	l := zap.New(&componentLevelCore{Core: core, levels: levels},
+		zap.AddCaller(),
+		zap.AddStacktrace(zapcore.ErrorLevel),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+	)
+	return l.Sugar(), nil
+}
+
+// componentLevelCore filters entries by the level of the component of the logger they are logged through
+type componentLevelCore struct {
+	zapcore.Core
+	levels *LogLevels
+}
+
+func (impl *componentLevelCore) Enabled(level zapcore.Level) bool {
+	return impl.levels.Enabled(level)
+}
+
+func (impl *componentLevelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &componentLevelCore{Core: impl.Core.With(fields), levels: impl.levels}
+}
+
+func (impl *componentLevelCore) Check(entry zapcore.Entry, checkedEntry *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !impl.levels.levelOf(entry.LoggerName).Enabled(entry.Level) {
+		return checkedEntry
 	}
-	Logger := l.Sugar()*/
-	return l.Sugar()
+	return impl.Core.Check(entry, checkedEntry)
 }
@@ -1,16 +1,22 @@
 package sql
 
 import (
+	"github.com/devtron-labs/chart-sync/internals/logger"
 	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"time"
 )
 
 type AppStoreApplicationVersionRepository interface {
 	FindVersionsByAppStoreId(appStoreId int) ([]*AppStoreApplicationVersion, error)
+	FindVersionsWithoutCreatedOrChartYaml(appStoreId int) ([]*AppStoreApplicationVersion, error)
+	FindVersionsWithoutChartType(appStoreId int) ([]*AppStoreApplicationVersion, error)
 	Save(versions *[]*AppStoreApplicationVersion) error
 	FindOneByAppStoreIdAndVersion(appStoreId int, version string) (*AppStoreApplicationVersion, error)
 	Update(appVersions []*AppStoreApplicationVersion) error
+	UpdateMetadata(appVersions []*AppStoreApplicationVersion) error
 }
 
 type AppStoreApplicationVersionRepositoryImpl struct {
@@ -19,7 +25,7 @@ type AppStoreApplicationVersionRepositoryImpl struct {
 }
 
 func NewAppStoreApplicationVersionRepositoryImpl(Logger *zap.SugaredLogger, dbConnection *pg.DB) *AppStoreApplicationVersionRepositoryImpl {
-	return &AppStoreApplicationVersionRepositoryImpl{dbConnection: dbConnection, Logger: Logger}
+	return &AppStoreApplicationVersionRepositoryImpl{dbConnection: dbConnection, Logger: Logger.Named(logger.ComponentSql)}
 }
 
 type AppStoreApplicationVersion struct {
@@ -33,7 +39,7 @@ type AppStoreApplicationVersion struct {
 	Digest      string    `sql:"digest"`
 	Icon        string    `sql:"icon"`
 	Name        string    `sql:"name"`
-	Source      string    `sql:"source"`
+	Source      string    `sql:"source"` // comma separated source urls of the chart
 	Home        string    `sql:"home"`
 	ValuesYaml  string    `sql:"values_yaml"`
 	ChartYaml   string    `sql:"chart_yaml"`
@@ -43,21 +49,103 @@ type AppStoreApplicationVersion struct {
 	Readme           string `sql:"readme"`
 	ValuesSchemaJson string `sql:"values_schema_json"`
 	Notes            string `sql:"notes"`
+	ChartArchiveUrl  string `sql:"chart_archive_url"`
+	Keywords         string `sql:"keywords"` // comma separated
+	KubeVersion      string `sql:"kube_version"`
+	ChartType        string `sql:"chart_type"`
 	AppStore         *AppStore
+	Maintainers      []*AppStoreApplicationVersionMaintainer `sql:"-"`
+	Dependencies     []*AppStoreApplicationVersionDependency `sql:"-"`
+}
+
+// MarshalLogObject logs the AppStoreApplicationVersion with the fields tagged secretData redacted
+func (appStoreApplicationVersion *AppStoreApplicationVersion) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return marshalRedacted(enc, appStoreApplicationVersion)
+}
+
+type AppStoreApplicationVersionMaintainer struct {
+	TableName                    struct{} `sql:"app_store_application_version_maintainer" pg:",discard_unknown_columns"`
+	Id                           int      `sql:"id,pk"`
+	AppStoreApplicationVersionId int      `sql:"app_store_application_version_id,notnull"`
+	Name                         string   `sql:"name"`
+	Email                        string   `sql:"email"`
+	Url                          string   `sql:"url"`
+}
+
+// MarshalLogObject logs the AppStoreApplicationVersionMaintainer with the fields tagged secretData redacted
+func (maintainer *AppStoreApplicationVersionMaintainer) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return marshalRedacted(enc, maintainer)
+}
+
+type AppStoreApplicationVersionDependency struct {
+	TableName                    struct{} `sql:"app_store_application_version_dependency" pg:",discard_unknown_columns"`
+	Id                           int      `sql:"id,pk"`
+	AppStoreApplicationVersionId int      `sql:"app_store_application_version_id,notnull"`
+	Name                         string   `sql:"name"`
+	Version                      string   `sql:"version"` // version constraint of the dependency
+	Repository                   string   `sql:"repository"`
+	Condition                    string   `sql:"condition"`
+	Tags                         string   `sql:"tags"` // comma separated
+	Alias                        string   `sql:"alias"`
+}
+
+// MarshalLogObject logs the AppStoreApplicationVersionDependency with the fields tagged secretData redacted
+func (dependency *AppStoreApplicationVersionDependency) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return marshalRedacted(enc, dependency)
 }
 
 func (impl AppStoreApplicationVersionRepositoryImpl) FindVersionsByAppStoreId(appStoreId int) ([]*AppStoreApplicationVersion, error) {
 	var appStoreApplicationVersion []*AppStoreApplicationVersion
 	err := impl.dbConnection.Model(&appStoreApplicationVersion).
-		Column("id", "version", "created").
+		Column("id", "version", "name", "created", "deprecated", "digest").
+		Where("app_store_id =?", appStoreId).
+		Select()
+	return appStoreApplicationVersion, err
+
+}
+
+// FindVersionsWithoutCreatedOrChartYaml returns the versions synced before their creation time was recorded or with the
+// tag as chart yaml, like OCI chart versions used to be synced
+func (impl AppStoreApplicationVersionRepositoryImpl) FindVersionsWithoutCreatedOrChartYaml(appStoreId int) ([]*AppStoreApplicationVersion, error) {
+	var appStoreApplicationVersion []*AppStoreApplicationVersion
+	err := impl.dbConnection.Model(&appStoreApplicationVersion).
+		Column("id", "version", "created", "deprecated", "chart_yaml", "created_on").
 		Where("app_store_id =?", appStoreId).
+		WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+			return q.WhereOr("created IS NULL").WhereOr("chart_yaml IS NULL").WhereOr("ltrim(chart_yaml::text) NOT LIKE '{%'"), nil
+		}).
 		Select()
 	return appStoreApplicationVersion, err
+}
 
+// FindVersionsWithoutChartType returns the versions synced before their structured metadata was recorded
+func (impl AppStoreApplicationVersionRepositoryImpl) FindVersionsWithoutChartType(appStoreId int) ([]*AppStoreApplicationVersion, error) {
+	var appStoreApplicationVersion []*AppStoreApplicationVersion
+	err := impl.dbConnection.Model(&appStoreApplicationVersion).
+		Column("id", "version", "chart_yaml", "chart_type").
+		Where("app_store_id =?", appStoreId).
+		WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+			return q.WhereOr("chart_type IS NULL").WhereOr("chart_type = ''"), nil
+		}).
+		Select()
+	return appStoreApplicationVersion, err
 }
 
 func (impl AppStoreApplicationVersionRepositoryImpl) Save(versions *[]*AppStoreApplicationVersion) error {
-	_, err := impl.dbConnection.Model(versions).OnConflict("DO NOTHING").Insert()
+	err := impl.dbConnection.RunInTransaction(func(tx *pg.Tx) error {
+		_, err := tx.Model(versions).OnConflict("DO NOTHING").Insert()
+		if err != nil {
+			return err
+		}
+		// ids returned by a batch insert skipping conflicting rows can not be matched to the versions, so they are fetched again
+		for _, version := range *versions {
+			_, err = tx.QueryOne(pg.Scan(&version.Id), "SELECT id FROM app_store_application_version WHERE app_store_id = ? AND version = ? LIMIT 1", version.AppStoreId, version.Version)
+			if err != nil {
+				return err
+			}
+		}
+		return saveVersionMetadata(tx, *versions)
+	})
 	return err
 }
 
@@ -83,3 +171,61 @@ func (impl AppStoreApplicationVersionRepositoryImpl) Update(appVersions []*AppSt
 	})
 	return err
 }
+
+// UpdateMetadata updates the structured metadata columns of the versions and replaces their maintainers and dependencies
+func (impl AppStoreApplicationVersionRepositoryImpl) UpdateMetadata(appVersions []*AppStoreApplicationVersion) error {
+	err := impl.dbConnection.RunInTransaction(func(tx *pg.Tx) error {
+		for _, version := range appVersions {
+			_, err := tx.Model(version).
+				Column("source", "keywords", "kube_version", "chart_type", "updated_on").
+				WherePK().
+				Update()
+			if err != nil {
+				return err
+			}
+		}
+		return saveVersionMetadata(tx, appVersions)
+	})
+	return err
+}
+
+func saveVersionMetadata(tx *pg.Tx, versions []*AppStoreApplicationVersion) error {
+	versionIds := make([]int, 0, len(versions))
+	var maintainers []*AppStoreApplicationVersionMaintainer
+	var dependencies []*AppStoreApplicationVersionDependency
+	for _, version := range versions {
+		versionIds = append(versionIds, version.Id)
+		for _, maintainer := range version.Maintainers {
+			maintainer.AppStoreApplicationVersionId = version.Id
+			maintainers = append(maintainers, maintainer)
+		}
+		for _, dependency := range version.Dependencies {
+			dependency.AppStoreApplicationVersionId = version.Id
+			dependencies = append(dependencies, dependency)
+		}
+	}
+	if len(versionIds) == 0 {
+		return nil
+	}
+	_, err := tx.Model((*AppStoreApplicationVersionMaintainer)(nil)).Where("app_store_application_version_id in (?)", pg.In(versionIds)).Delete()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Model((*AppStoreApplicationVersionDependency)(nil)).Where("app_store_application_version_id in (?)", pg.In(versionIds)).Delete()
+	if err != nil {
+		return err
+	}
+	if len(maintainers) > 0 {
+		_, err = tx.Model(&maintainers).Insert()
+		if err != nil {
+			return err
+		}
+	}
+	if len(dependencies) > 0 {
+		_, err = tx.Model(&dependencies).Insert()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
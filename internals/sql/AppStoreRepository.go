@@ -1,14 +1,17 @@
 package sql
 
 import (
+	"github.com/devtron-labs/chart-sync/internals/logger"
 	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"time"
 )
 
 type AppStoreRepository interface {
 	FindByStoreId(storeId string) (appStores []*AppStore, err error)
-	FindInactiveOneByName(storeId, name string) (appStore *AppStore, err error)
+	FindInactiveOneByRepositoryPath(storeId, repositoryPath string) (appStore *AppStore, err error)
 	FindByRepoId(repoId int) (appStores []*AppStore, err error)
 	Save(appStore *AppStore) error
 	Update(appStore []*AppStore) error
@@ -20,22 +23,33 @@ type AppStoreRepositoryImpl struct {
 }
 
 func NewAppStoreRepositoryImpl(Logger *zap.SugaredLogger, dbConnection *pg.DB) *AppStoreRepositoryImpl {
-	return &AppStoreRepositoryImpl{dbConnection: dbConnection, Logger: Logger}
+	return &AppStoreRepositoryImpl{dbConnection: dbConnection, Logger: Logger.Named(logger.ComponentSql)}
 }
 
 type AppStore struct {
 	TableName             struct{}  `sql:"app_store" pg:",discard_unknown_columns"`
 	Id                    int       `sql:"id,pk"`
 	Name                  string    `sql:"name,notnull"`
+	RepositoryPath        string    `sql:"repository_path"` // path of the repository relative to the registry url for OCI charts, like team-a/redis
+	ChartName             string    `sql:"chart_name"`      // name of the chart in Chart.yaml
+	DisplayName           string    `sql:"display_name"`
 	ChartRepoId           int       `sql:"chart_repo_id"`
 	DockerArtifactStoreId string    `sql:"docker_artifact_store_id"`
 	Active                bool      `sql:"active,notnull"`
 	ChartGitLocation      string    `sql:"chart_git_location"`
+	TagListCursor         string    `sql:"tag_list_cursor"`      // last tag listed for OCI repositories, next runs only list tags sorting after it
+	TagsFullyListedOn     time.Time `sql:"tags_fully_listed_on"` // last time all tags of the OCI repository were listed
+	VersionFilter         string    `sql:"version_filter"`       // json of util.VersionFilterConfig overriding the version filter of the chart provider
 	CreatedOn             time.Time `sql:"created_on,notnull"`
 	UpdatedOn             time.Time `sql:"updated_on,notnull"`
 	ChartRepo             ChartRepo
 }
 
+// MarshalLogObject logs the AppStore with the fields tagged secretData redacted
+func (appStore *AppStore) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return marshalRedacted(enc, appStore)
+}
+
 func (impl *AppStoreRepositoryImpl) FindByRepoId(repoId int) (appStores []*AppStore, err error) {
 	err = impl.dbConnection.Model(&appStores).
 		Where("chart_repo_id =?", repoId).
@@ -51,19 +65,39 @@ func (impl *AppStoreRepositoryImpl) FindByStoreId(storeId string) (appStores []*
 		Select()
 	return appStores, err
 }
-func (impl *AppStoreRepositoryImpl) FindInactiveOneByName(storeId, name string) (*AppStore, error) {
+
+// FindInactiveOneByRepositoryPath finds the inactive app of the repository of an OCI registry. Apps synced before the
+// repository path was stored are matched by name, which used to be the repository path.
+func (impl *AppStoreRepositoryImpl) FindInactiveOneByRepositoryPath(storeId, repositoryPath string) (*AppStore, error) {
 	appStore := AppStore{}
 	err := impl.dbConnection.Model(&appStore).
 		Where("docker_artifact_store_id =?", storeId).
-		Where("name =?", name).
+		WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+			q = q.WhereOr("repository_path =?", repositoryPath).
+				WhereOrGroup(func(q *orm.Query) (*orm.Query, error) {
+					q = q.Where("COALESCE(repository_path, '') = ''").
+						Where("name =?", repositoryPath)
+					return q, nil
+				})
+			return q, nil
+		}).
 		Where("active =?", false).
+		Limit(1).
 		Select()
 	if err != nil && err != pg.ErrNoRows {
-		impl.Logger.Errorw("error in fetching inactive app for name", "ChartName", name, "err", err)
+		impl.Logger.Errorw("error in fetching inactive app for repository path", "repositoryPath", repositoryPath, "err", err)
 	}
 	return &appStore, err
 }
 
+// GetRepositoryPath returns the repository path of the app, apps synced before it was stored have it as name
+func (appStore *AppStore) GetRepositoryPath() string {
+	if len(appStore.RepositoryPath) > 0 {
+		return appStore.RepositoryPath
+	}
+	return appStore.Name
+}
+
 func (impl *AppStoreRepositoryImpl) Save(appStore *AppStore) error {
 	return impl.dbConnection.Insert(appStore)
 }
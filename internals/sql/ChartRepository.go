@@ -2,28 +2,81 @@ package sql
 
 import (
 	"github.com/go-pg/pg"
+	"go.uber.org/zap/zapcore"
 )
 
 //---------------------------chart repository------------------
 
+type AuthMode string
+
+const (
+	AUTH_MODE_ANONYMOUS         AuthMode = "ANONYMOUS"
+	AUTH_MODE_USERNAME_PASSWORD AuthMode = "USERNAME_PASSWORD"
+	AUTH_MODE_ACCESS_TOKEN      AuthMode = "ACCESS_TOKEN"
+	AUTH_MODE_CUSTOM_HEADERS    AuthMode = "CUSTOM_HEADERS"
+)
+
 type ChartRepo struct {
-	tableName               struct{} `sql:"chart_repo"`
-	Id                      int      `sql:"id,pk"`
-	Name                    string   `sql:"name"`
-	Url                     string   `sql:"url"`
-	Active                  bool     `sql:"active"`
-	Deleted                 bool     `sql:"deleted"`
-	Default                 bool     `sql:"is_default"`
-	External                bool     `sql:"external"`
-	Username                string   `sql:"user_name"`
-	Password                string   `sql:"password"`
-	CertFile                string   `sql:"-"`
-	KeyFile                 string   `sql:"-"`
-	CAFile                  string   `sql:"-"`
-	AllowInsecureConnection bool     `sql:"allow_insecure_connection"`
+	tableName                struct{}                `sql:"chart_repo"`
+	Id                       int                     `sql:"id,pk"`
+	Name                     string                  `sql:"name"`
+	Url                      string                  `sql:"url"`
+	Active                   bool                    `sql:"active"`
+	Deleted                  bool                    `sql:"deleted"`
+	Default                  bool                    `sql:"is_default"`
+	External                 bool                    `sql:"external"`
+	Username                 string                  `sql:"user_name"`
+	Password                 string                  `sql:"password" secretData:"-"`
+	CertFile                 string                  `sql:"-"`
+	KeyFile                  string                  `sql:"-" secretData:"-"`
+	CAFile                   string                  `sql:"-"`
+	AllowInsecureConnection  bool                    `sql:"allow_insecure_connection"`
+	AuthMode                 AuthMode                `sql:"auth_mode"`
+	AccessToken              string                  `sql:"access_token" secretData:"-"`
+	CustomHeaders            string                  `sql:"custom_headers" secretData:"-"` // json object of header name to header value, used with AUTH_MODE_CUSTOM_HEADERS
+	PassCredentialsAll       bool                    `sql:"pass_credentials_all"`
+	MirrorUrls               string                  `sql:"mirror_urls"`    // comma separated base urls of mirrors serving the same chart archives
+	VersionFilter            string                  `sql:"version_filter"` // json of util.VersionFilterConfig applied to the versions of all charts of the repo
+	RemoteConnectionConfigId int                     `sql:"remote_connection_config_id"`
+	RemoteConnectionConfig   *RemoteConnectionConfig `sql:"-"`
 	AuditLog
 }
 
+// MarshalLogObject logs the ChartRepo with the fields tagged secretData redacted
+func (chartRepo *ChartRepo) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return marshalRedacted(enc, chartRepo)
+}
+
+// ChartRepoLogView is the log-safe view of a chart repo. Unlike MarshalLogObject which redacts the fields known to
+// hold credentials, it only holds fields known not to, so that fields added later can not leak into logs.
+type ChartRepoLogView struct {
+	Id                       int      `json:"id"`
+	Name                     string   `json:"name"`
+	Url                      string   `json:"url"`
+	Active                   bool     `json:"active"`
+	AuthMode                 AuthMode `json:"authMode"`
+	HasCredentials           bool     `json:"hasCredentials"`
+	AllowInsecureConnection  bool     `json:"allowInsecureConnection"`
+	RemoteConnectionConfigId int      `json:"remoteConnectionConfigId,omitempty"`
+}
+
+// LogSafe returns the log-safe view of the chart repo
+func (chartRepo *ChartRepo) LogSafe() *ChartRepoLogView {
+	if chartRepo == nil {
+		return nil
+	}
+	return &ChartRepoLogView{
+		Id:                       chartRepo.Id,
+		Name:                     chartRepo.Name,
+		Url:                      redactUrls(chartRepo.Url),
+		Active:                   chartRepo.Active,
+		AuthMode:                 chartRepo.AuthMode,
+		HasCredentials:           len(chartRepo.Password) > 0 || len(chartRepo.AccessToken) > 0 || len(chartRepo.CustomHeaders) > 0,
+		AllowInsecureConnection:  chartRepo.AllowInsecureConnection,
+		RemoteConnectionConfigId: chartRepo.RemoteConnectionConfigId,
+	}
+}
+
 type ChartRepoRepository interface {
 	Save(chartRepo *ChartRepo) error
 	GetDefault() (*ChartRepo, error)
@@ -68,5 +121,3 @@ func (impl *ChartRepoRepositoryImpl) GetAll() (repos []*ChartRepo, err error) {
 		Select()
 	return repos, err
 }
-
-
@@ -20,6 +20,7 @@ package sql
 import (
 	"github.com/go-pg/pg"
 	"github.com/go-pg/pg/orm"
+	"go.uber.org/zap/zapcore"
 )
 
 const (
@@ -41,20 +42,22 @@ type RegistryType string
 var OCI_REGISRTY_REPO_TYPE_LIST = []string{OCI_REGISRTY_REPO_TYPE_CONTAINER, OCI_REGISRTY_REPO_TYPE_CHART}
 
 type DockerArtifactStore struct {
-	tableName                struct{}     `sql:"docker_artifact_store" json:",omitempty"  pg:",discard_unknown_columns"`
+	tableName                struct{}     `sql:"docker_artifact_store" pg:",discard_unknown_columns"`
 	Id                       string       `sql:"id,pk" json:"id,,omitempty"`
 	PluginId                 string       `sql:"plugin_id,notnull" json:"pluginId,omitempty"`
 	RegistryURL              string       `sql:"registry_url" json:"registryUrl,omitempty"`
 	RegistryType             RegistryType `sql:"registry_type,notnull" json:"registryType,omitempty"`
 	IsOCICompliantRegistry   bool         `sql:"is_oci_compliant_registry,notnull" json:"isOCICompliantRegistry,omitempty"`
-	AWSAccessKeyId           string       `sql:"aws_accesskey_id" json:"awsAccessKeyId,omitempty" `
-	AWSSecretAccessKey       string       `sql:"aws_secret_accesskey" json:"awsSecretAccessKey,omitempty"`
+	AWSAccessKeyId           string       `sql:"aws_accesskey_id" json:"awsAccessKeyId,omitempty" secretData:"-"`
+	AWSSecretAccessKey       string       `sql:"aws_secret_accesskey" json:"awsSecretAccessKey,omitempty" secretData:"-"`
 	AWSRegion                string       `sql:"aws_region" json:"awsRegion,omitempty"`
 	Username                 string       `sql:"username" json:"username,omitempty"`
-	Password                 string       `sql:"password" json:"password,omitempty"`
+	Password                 string       `sql:"password" json:"password,omitempty" secretData:"-"`
 	IsDefault                bool         `sql:"is_default,notnull" json:"isDefault"`
 	Connection               string       `sql:"connection" json:"connection,omitempty"`
-	Cert                     string       `sql:"cert" json:"cert,omitempty"`
+	Cert                     string       `sql:"cert" json:"cert,omitempty" secretData:"-"`
+	ClientCert               string       `sql:"client_cert" json:"clientCert,omitempty" secretData:"-"` // PEM encoded client certificate for registries requiring mutual TLS
+	ClientKey                string       `sql:"client_key" json:"clientKey,omitempty" secretData:"-"`   // PEM encoded private key of the client certificate
 	Active                   bool         `sql:"active,notnull" json:"active"`
 	RemoteConnectionConfigId int          `sql:"remote_connection_config_id"`
 	OCIRegistryConfig        []*OCIRegistryConfig
@@ -62,6 +65,46 @@ type DockerArtifactStore struct {
 	AuditLog
 }
 
+// MarshalLogObject logs the DockerArtifactStore with the fields tagged secretData redacted
+func (store *DockerArtifactStore) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return marshalRedacted(enc, store)
+}
+
+// DockerArtifactStoreLogView is the log-safe view of a docker artifact store. Unlike MarshalLogObject which redacts the
+// fields known to hold credentials, it only holds fields known not to, so that fields added later can not leak into logs.
+type DockerArtifactStoreLogView struct {
+	Id                       string       `json:"id"`
+	RegistryURL              string       `json:"registryUrl"`
+	RegistryType             RegistryType `json:"registryType"`
+	Connection               string       `json:"connection"`
+	Active                   bool         `json:"active"`
+	HasCredentials           bool         `json:"hasCredentials"`
+	HasClientCertificate     bool         `json:"hasClientCertificate"`
+	OCIRegistryConfigIds     []int        `json:"ociRegistryConfigIds,omitempty"`
+	RemoteConnectionConfigId int          `json:"remoteConnectionConfigId,omitempty"`
+}
+
+// LogSafe returns the log-safe view of the store
+func (store *DockerArtifactStore) LogSafe() *DockerArtifactStoreLogView {
+	if store == nil {
+		return nil
+	}
+	logView := &DockerArtifactStoreLogView{
+		Id:                       store.Id,
+		RegistryURL:              redactUrls(store.RegistryURL),
+		RegistryType:             store.RegistryType,
+		Connection:               store.Connection,
+		Active:                   store.Active,
+		HasCredentials:           len(store.Username) > 0 || len(store.Password) > 0 || len(store.AWSSecretAccessKey) > 0,
+		HasClientCertificate:     len(store.ClientCert) > 0,
+		RemoteConnectionConfigId: store.RemoteConnectionConfigId,
+	}
+	for _, ociRegistryConfig := range store.OCIRegistryConfig {
+		logView.OCIRegistryConfigIds = append(logView.OCIRegistryConfigIds, ociRegistryConfig.Id)
+	}
+	return logView
+}
+
 type DockerArtifactStoreRepository interface {
 	FindAllChartProviders() ([]*DockerArtifactStore, error)
 	FindOne(storeId string) (*DockerArtifactStore, error)
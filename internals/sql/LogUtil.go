@@ -0,0 +1,134 @@
+package sql
+
+import (
+	"go.uber.org/zap/zapcore"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const redactedValue = "********"
+
+var (
+	objectMarshalerType = reflect.TypeOf((*zapcore.ObjectMarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+// marshalRedacted encodes the exported fields of a model for zap replacing the values of fields tagged secretData,
+// like obfuscateSecretTags does for the db config. Nested structs are encoded field by field as well, so that
+// credentials of related models are redacted too.
+func marshalRedacted(enc zapcore.ObjectEncoder, model interface{}) error {
+	value := reflect.ValueOf(model)
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	return encodeRedactedFields(enc, value)
+}
+
+func encodeRedactedFields(enc zapcore.ObjectEncoder, value reflect.Value) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if !field.IsExported() || field.Type == reflect.TypeOf(struct{}{}) {
+			continue
+		}
+		fieldValue := value.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			// embedded structs like AuditLog are flattened as their fields are promoted
+			if err := encodeRedactedFields(enc, fieldValue); err != nil {
+				return err
+			}
+			continue
+		}
+		key := getLogKey(field)
+		if _, ok := field.Tag.Lookup("secretData"); ok {
+			// empty credentials are kept empty so that missing credentials can still be told apart in logs
+			if fieldValue.IsZero() {
+				enc.AddString(key, "")
+			} else {
+				enc.AddString(key, redactedValue)
+			}
+			continue
+		}
+		if field.Type.Kind() == reflect.String && isUrlField(field.Name) {
+			enc.AddString(key, redactUrls(fieldValue.String()))
+			continue
+		}
+		if err := encodeRedactedValue(enc, key, fieldValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeRedactedValue(enc zapcore.ObjectEncoder, key string, value reflect.Value) error {
+	switch {
+	case value.Type() == timeType:
+		enc.AddTime(key, value.Interface().(time.Time))
+		return nil
+	case value.Kind() == reflect.Ptr && value.IsNil():
+		enc.AddReflected(key, nil)
+		return nil
+	case value.Kind() == reflect.Struct || (value.Kind() == reflect.Ptr && value.Elem().Kind() == reflect.Struct):
+		return enc.AddObject(key, redactedObject(value))
+	case value.Kind() == reflect.Slice && isStructElem(value.Type().Elem()):
+		return enc.AddArray(key, zapcore.ArrayMarshalerFunc(func(arrayEnc zapcore.ArrayEncoder) error {
+			for i := 0; i < value.Len(); i++ {
+				if err := arrayEnc.AppendObject(redactedObject(value.Index(i))); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+	default:
+		return enc.AddReflected(key, value.Interface())
+	}
+}
+
+// redactedObject returns the ObjectMarshaler of a struct or a pointer to a struct, which is the struct's own one when
+// it has one
+func redactedObject(value reflect.Value) zapcore.ObjectMarshaler {
+	if value.Kind() == reflect.Struct && value.CanAddr() {
+		value = value.Addr()
+	}
+	if value.Type().Implements(objectMarshalerType) {
+		if value.Kind() == reflect.Ptr && value.IsNil() {
+			return zapcore.ObjectMarshalerFunc(func(zapcore.ObjectEncoder) error { return nil })
+		}
+		return value.Interface().(zapcore.ObjectMarshaler)
+	}
+	return zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		return marshalRedacted(enc, value.Interface())
+	})
+}
+
+func isStructElem(elemType reflect.Type) bool {
+	return elemType.Kind() == reflect.Struct || (elemType.Kind() == reflect.Ptr && elemType.Elem().Kind() == reflect.Struct)
+}
+
+// getLogKey returns the json name of the field, the field name when it has none
+func getLogKey(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); len(name) > 0 && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+func isUrlField(fieldName string) bool {
+	return strings.HasSuffix(fieldName, "Url") || strings.HasSuffix(fieldName, "URL") || strings.HasSuffix(fieldName, "Urls")
+}
+
+// redactUrls masks the passwords of comma separated urls with user info, invalid urls are returned as they are
+func redactUrls(rawUrls string) string {
+	urls := strings.Split(rawUrls, ",")
+	for i, rawUrl := range urls {
+		if parsedUrl, err := url.Parse(strings.TrimSpace(rawUrl)); err == nil {
+			urls[i] = parsedUrl.Redacted()
+		}
+	}
+	return strings.Join(urls, ",")
+}
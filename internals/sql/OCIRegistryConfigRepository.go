@@ -19,6 +19,7 @@ package sql
 
 import (
 	"github.com/go-pg/pg"
+	"go.uber.org/zap/zapcore"
 )
 
 type OCIRegistryConfig struct {
@@ -30,10 +31,20 @@ type OCIRegistryConfig struct {
 	RepositoryList        string   `sql:"repository_list"`
 	IsChartPullActive     bool     `sql:"is_chart_pull_active"`
 	IsPublic              bool     `sql:"is_public"`
-	Deleted               bool     `sql:"deleted,notnull"`
+	// repositories of the registry holding helm charts are discovered through the catalog endpoint along with RepositoryList,
+	// limited to the repositories starting with RepositoryDiscoveryPrefix
+	IsRepositoryDiscoveryEnabled bool   `sql:"is_repository_discovery_enabled"`
+	RepositoryDiscoveryPrefix    string `sql:"repository_discovery_prefix"`
+	VersionFilter                string `sql:"version_filter"` // json of util.VersionFilterConfig applied to the tags of all repositories
+	Deleted                      bool   `sql:"deleted,notnull"`
 	AuditLog
 }
 
+// MarshalLogObject logs the OCIRegistryConfig with the fields tagged secretData redacted
+func (ociRegistryConfig *OCIRegistryConfig) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return marshalRedacted(enc, ociRegistryConfig)
+}
+
 type OCIRegistryConfigRepository interface {
 	FindByDockerRegistryId(dockerRegistryId string) ([]*OCIRegistryConfig, error)
 	FindOneByDockerRegistryIdAndRepositoryType(dockerRegistryId string, repositoryType string) (*OCIRegistryConfig, error)
@@ -0,0 +1,152 @@
+package sql
+
+import (
+	"errors"
+	"github.com/go-pg/pg"
+)
+
+// ProviderRepository updates rows of the chart repo, docker artifact store and remote connection config tables together
+type ProviderRepository interface {
+	// UpsertProviders writes the chart repos and the registries along with their chart pull configs in one transaction.
+	// Chart repos are matched by name, registries by id and the chart pull configs of a registry by their position, so
+	// that the ids of existing rows are kept. Chart pull configs of a registry beyond its ones are deleted.
+	UpsertProviders(chartRepos []*ChartRepo, stores []*DockerArtifactStore) error
+	// UpdateCredentials updates the credential columns of the rows in one transaction, none of them are updated when
+	// any update fails
+	UpdateCredentials(chartRepos []*ChartRepo, stores []*DockerArtifactStore, remoteConnectionConfigs []*RemoteConnectionConfig) error
+}
+
+type ProviderRepositoryImpl struct {
+	dbConnection *pg.DB
+}
+
+func NewProviderRepositoryImpl(dbConnection *pg.DB) *ProviderRepositoryImpl {
+	return &ProviderRepositoryImpl{dbConnection: dbConnection}
+}
+
+func (impl *ProviderRepositoryImpl) UpdateCredentials(chartRepos []*ChartRepo, stores []*DockerArtifactStore, remoteConnectionConfigs []*RemoteConnectionConfig) error {
+	return impl.dbConnection.RunInTransaction(func(tx *pg.Tx) error {
+		for _, chartRepo := range chartRepos {
+			_, err := tx.Model(chartRepo).
+				Column("password", "access_token", "custom_headers").
+				WherePK().
+				Update()
+			if err != nil {
+				return err
+			}
+		}
+		for _, store := range stores {
+			_, err := tx.Model(store).
+				Column("password", "aws_secret_accesskey", "client_key").
+				WherePK().
+				Update()
+			if err != nil {
+				return err
+			}
+		}
+		for _, remoteConnectionConfig := range remoteConnectionConfigs {
+			_, err := tx.Model(remoteConnectionConfig).
+				Column("ssh_password", "ssh_auth_key").
+				WherePK().
+				Update()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (impl *ProviderRepositoryImpl) UpsertProviders(chartRepos []*ChartRepo, stores []*DockerArtifactStore) error {
+	return impl.dbConnection.RunInTransaction(func(tx *pg.Tx) error {
+		for _, chartRepo := range chartRepos {
+			existingChartRepo := &ChartRepo{}
+			err := tx.Model(existingChartRepo).
+				Where("name = ?", chartRepo.Name).
+				Where("deleted = ?", false).
+				Limit(1).Select()
+			if err != nil && !errors.Is(err, pg.ErrNoRows) {
+				return err
+			}
+			if err == nil {
+				chartRepo.Id = existingChartRepo.Id
+				chartRepo.Default = existingChartRepo.Default
+				chartRepo.RemoteConnectionConfigId = existingChartRepo.RemoteConnectionConfigId
+				chartRepo.CreatedOn = existingChartRepo.CreatedOn
+				chartRepo.CreatedBy = existingChartRepo.CreatedBy
+				err = tx.Update(chartRepo)
+			} else {
+				err = tx.Insert(chartRepo)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		for _, store := range stores {
+			// the default flag, remote connection and creation audit of an existing store are kept
+			_, err := tx.Model(store).
+				OnConflict("(id) DO UPDATE").
+				Set("plugin_id = EXCLUDED.plugin_id").
+				Set("registry_url = EXCLUDED.registry_url").
+				Set("registry_type = EXCLUDED.registry_type").
+				Set("is_oci_compliant_registry = EXCLUDED.is_oci_compliant_registry").
+				Set("aws_accesskey_id = EXCLUDED.aws_accesskey_id").
+				Set("aws_secret_accesskey = EXCLUDED.aws_secret_accesskey").
+				Set("aws_region = EXCLUDED.aws_region").
+				Set("username = EXCLUDED.username").
+				Set("password = EXCLUDED.password").
+				Set("connection = EXCLUDED.connection").
+				Set("cert = EXCLUDED.cert").
+				Set("client_cert = EXCLUDED.client_cert").
+				Set("client_key = EXCLUDED.client_key").
+				Set("active = EXCLUDED.active").
+				Set("updated_on = EXCLUDED.updated_on").
+				Set("updated_by = EXCLUDED.updated_by").
+				Insert()
+			if err != nil {
+				return err
+			}
+			err = upsertChartPullConfigs(tx, store)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func upsertChartPullConfigs(tx *pg.Tx, store *DockerArtifactStore) error {
+	var existingConfigs []*OCIRegistryConfig
+	err := tx.Model(&existingConfigs).
+		Where("docker_artifact_store_id = ?", store.Id).
+		Where("repository_type = ?", OCI_REGISRTY_REPO_TYPE_CHART).
+		Where("deleted = ?", false).
+		Order("id").
+		Select()
+	if err != nil && !errors.Is(err, pg.ErrNoRows) {
+		return err
+	}
+	for i, ociRegistryConfig := range store.OCIRegistryConfig {
+		if i < len(existingConfigs) {
+			ociRegistryConfig.Id = existingConfigs[i].Id
+			ociRegistryConfig.CreatedOn = existingConfigs[i].CreatedOn
+			ociRegistryConfig.CreatedBy = existingConfigs[i].CreatedBy
+			err = tx.Update(ociRegistryConfig)
+		} else {
+			err = tx.Insert(ociRegistryConfig)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for i := len(store.OCIRegistryConfig); i < len(existingConfigs); i++ {
+		existingConfigs[i].Deleted = true
+		existingConfigs[i].UpdatedOn = store.UpdatedOn
+		existingConfigs[i].UpdatedBy = store.UpdatedBy
+		err = tx.Update(existingConfigs[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package sql
 
 import (
+	logger2 "github.com/devtron-labs/chart-sync/internals/logger"
 	"reflect"
 	"time"
 
@@ -26,6 +27,7 @@ func GetConfig() (*Config, error) {
 }
 
 func NewDbConnection(cfg *Config, logger *zap.SugaredLogger) (*pg.DB, error) {
+	logger = logger.Named(logger2.ComponentSql)
 	options := pg.Options{
 		Addr:            cfg.Addr + ":" + cfg.Port,
 		User:            cfg.User,
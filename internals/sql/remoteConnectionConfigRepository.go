@@ -1,8 +1,10 @@
 package sql
 
 import (
+	logger2 "github.com/devtron-labs/chart-sync/internals/logger"
 	"github.com/go-pg/pg"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 type RemoteConnectionConfig struct {
@@ -12,12 +14,17 @@ type RemoteConnectionConfig struct {
 	ProxyUrl         string   `sql:"proxy_url"`
 	SSHServerAddress string   `sql:"ssh_server_address"`
 	SSHUsername      string   `sql:"ssh_username"`
-	SSHPassword      string   `sql:"ssh_password"`
-	SSHAuthKey       string   `sql:"ssh_auth_key"`
+	SSHPassword      string   `sql:"ssh_password" secretData:"-"`
+	SSHAuthKey       string   `sql:"ssh_auth_key" secretData:"-"`
 	Deleted          bool     `sql:"deleted,notnull"`
 	AuditLog
 }
 
+// MarshalLogObject logs the RemoteConnectionConfig with the fields tagged secretData redacted
+func (remoteConnectionConfig *RemoteConnectionConfig) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return marshalRedacted(enc, remoteConnectionConfig)
+}
+
 type RemoteConnectionRepository interface {
 	GetById(id int) (*RemoteConnectionConfig, error)
 }
@@ -29,7 +36,7 @@ type RemoteConnectionRepositoryImpl struct {
 
 func NewRemoteConnectionRepositoryImpl(dbConnection *pg.DB, logger *zap.SugaredLogger) *RemoteConnectionRepositoryImpl {
 	return &RemoteConnectionRepositoryImpl{
-		logger:       logger,
+		logger:       logger.Named(logger2.ComponentSql),
 		dbConnection: dbConnection,
 	}
 }
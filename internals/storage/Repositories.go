@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/devtron-labs/chart-sync/internals"
+	"github.com/devtron-labs/chart-sync/internals/filestore"
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"github.com/go-pg/pg"
+	"go.uber.org/zap"
+)
+
+const (
+	StorageBackendPostgres = "postgres"
+	StorageBackendFile     = "file"
+)
+
+// Repositories are the repositories of the storage backend selected by STORAGE_BACKEND
+type Repositories struct {
+	DB                                   *pg.DB // nil unless the backend is postgres
+	ChartRepoRepository                  sql.ChartRepoRepository
+	DockerArtifactStoreRepository        sql.DockerArtifactStoreRepository
+	OCIRegistryConfigRepository          sql.OCIRegistryConfigRepository
+	RemoteConnectionRepository           sql.RemoteConnectionRepository
+	AppStoreRepository                   sql.AppStoreRepository
+	AppStoreApplicationVersionRepository sql.AppStoreApplicationVersionRepository
+	ProviderRepository                   sql.ProviderRepository
+}
+
+// NewRepositories only connects to the database when the backend is postgres, so that the file backend runs without it
+func NewRepositories(logger *zap.SugaredLogger, configuration *internals.Configuration, dbConfig *sql.Config) (*Repositories, error) {
+	switch configuration.StorageBackend {
+	case StorageBackendPostgres:
+		db, err := sql.NewDbConnection(dbConfig, logger)
+		if err != nil {
+			return nil, err
+		}
+		return &Repositories{
+			DB:                                   db,
+			ChartRepoRepository:                  sql.NewChartRepoRepositoryImpl(db),
+			DockerArtifactStoreRepository:        sql.NewDockerArtifactStoreRepositoryImpl(db),
+			OCIRegistryConfigRepository:          sql.NewOCIRegistryConfigRepositoryImpl(db),
+			RemoteConnectionRepository:           sql.NewRemoteConnectionRepositoryImpl(db, logger),
+			AppStoreRepository:                   sql.NewAppStoreRepositoryImpl(logger, db),
+			AppStoreApplicationVersionRepository: sql.NewAppStoreApplicationVersionRepositoryImpl(logger, db),
+			ProviderRepository:                   sql.NewProviderRepositoryImpl(db),
+		}, nil
+	case StorageBackendFile:
+		fileStore, err := filestore.NewFileStore(logger, configuration.StorageDir)
+		if err != nil {
+			return nil, err
+		}
+		return &Repositories{
+			ChartRepoRepository:                  filestore.NewChartRepoRepositoryImpl(fileStore),
+			DockerArtifactStoreRepository:        filestore.NewDockerArtifactStoreRepositoryImpl(fileStore),
+			OCIRegistryConfigRepository:          filestore.NewOCIRegistryConfigRepositoryImpl(fileStore),
+			RemoteConnectionRepository:           filestore.NewRemoteConnectionRepositoryImpl(fileStore),
+			AppStoreRepository:                   filestore.NewAppStoreRepositoryImpl(fileStore),
+			AppStoreApplicationVersionRepository: filestore.NewAppStoreApplicationVersionRepositoryImpl(fileStore),
+			ProviderRepository:                   filestore.NewProviderRepositoryImpl(fileStore),
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid STORAGE_BACKEND %s, must be postgres or file", configuration.StorageBackend)
+	}
+}
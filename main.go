@@ -1,13 +1,35 @@
 package main
 
 import (
+	"flag"
+	"github.com/devtron-labs/chart-sync/pkg/secret"
 	"log"
+	"os"
 )
 
+// reEncryptCredentialsCommand encrypts the stored credentials of chart providers instead of syncing them, like
+// reencrypt-credentials --dry-run --encrypt-cleartext. Run with CREDENTIAL_PREVIOUS_KEK_FILE set to the old key to
+// rotate the key encryption key.
+const reEncryptCredentialsCommand = "reencrypt-credentials"
+
 func main() {
 	app, err := InitializeApp()
 	if err != nil {
 		log.Panic(err)
 	}
-	app.Start()
+	if len(os.Args) > 1 && os.Args[1] == reEncryptCredentialsCommand {
+		options := secret.ReEncryptOptions{}
+		flags := flag.NewFlagSet(reEncryptCredentialsCommand, flag.ExitOnError)
+		flags.BoolVar(&options.DryRun, "dry-run", false, "only log the rows whose credentials would be encrypted")
+		flags.BoolVar(&options.EncryptCleartext, "encrypt-cleartext", false,
+			"encrypt cleartext credentials too, only once the orchestrator decrypts enc:// credentials")
+		_ = flags.Parse(os.Args[2:])
+		if err := app.ReEncryptCredentials(options); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+	if err := app.Start(); err != nil {
+		os.Exit(1)
+	}
 }
@@ -2,20 +2,25 @@ package pkg
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	logger2 "github.com/devtron-labs/chart-sync/internals/logger"
 	"github.com/devtron-labs/chart-sync/internals/sql"
+	registry3 "github.com/devtron-labs/chart-sync/pkg/registry"
 	"github.com/devtron-labs/chart-sync/util"
-	registry2 "github.com/devtron-labs/common-lib/helmLib/registry"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"go.uber.org/zap"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/cli"
-	"helm.sh/helm/v3/pkg/getter"
 	"helm.sh/helm/v3/pkg/registry"
 	"helm.sh/helm/v3/pkg/repo"
 	"net/url"
+	orasRegistry "oras.land/oras-go/pkg/registry"
 	"path"
 	"strings"
+	"time"
 )
 
 const (
@@ -25,11 +30,12 @@ const (
 )
 
 type HelmRepoManager interface {
-	LoadIndexFile(chartRepo *sql.ChartRepo) (*repo.IndexFile, error)
-	ValuesJson(repoUrl string, version *repo.ChartVersion, username string, password string, allowInsecureConnection bool) (rawValues string, readme string, valuesSchemaJson string, notes string, err error)
+	LoadIndexFile(chartRepo *sql.ChartRepo, chartRepoGetter *util.ChartRepoGetter) (*repo.IndexFile, error)
+	ValuesJson(chartRepo *sql.ChartRepo, version *repo.ChartVersion, chartRepoGetter *util.ChartRepoGetter, registryClientGetter RegistryClientGetter) (chartData ChartData, err error)
 	OCIRepoValuesJson(client *registry.Client, registryUrl, chartName, version string) (chartData ChartData, err error)
-	RegistryLogin(client *registry.Client, store *sql.DockerArtifactStore, username, password string) error
-	FetchOCIChartTagsList(settings *registry2.Settings, ociRepoURL string) ([]string, error)
+	FetchOCIChartTagsList(ociClient *registry3.OCIClient, repository, last string, pageSize int) ([]string, error)
+	FetchOCIChartManifest(ociClient *registry3.OCIClient, repository, tag string) (*OCIChartManifest, error)
+	LoadOCIChartContent(ociClient *registry3.OCIClient, repository string, chartManifest *OCIChartManifest) (ChartData, error)
 	LoadChartFromOCIRepo(client *registry.Client, registryUrl, chartName, version string) (*chart.Chart, string, error)
 }
 
@@ -40,23 +46,18 @@ type HelmRepoManagerImpl struct {
 
 func NewHelmRepoManagerImpl(logger *zap.SugaredLogger) *HelmRepoManagerImpl {
 	return &HelmRepoManagerImpl{
-		Logger:   logger,
+		Logger:   logger.Named(logger2.ComponentHelm),
 		Settings: cli.New(),
 	}
 }
 
-func (impl *HelmRepoManagerImpl) LoadIndexFile(chartRepo *sql.ChartRepo) (*repo.IndexFile, error) {
+func (impl *HelmRepoManagerImpl) LoadIndexFile(chartRepo *sql.ChartRepo, chartRepoGetter *util.ChartRepoGetter) (*repo.IndexFile, error) {
+	// credentials and tls settings are applied by chartRepoGetter
 	helmRepoConfig := &repo.Entry{
-		Name:                  chartRepo.Name,
-		URL:                   chartRepo.Url,
-		Username:              chartRepo.Username,
-		Password:              chartRepo.Password,
-		CertFile:              chartRepo.CertFile,
-		KeyFile:               chartRepo.KeyFile,
-		CAFile:                chartRepo.CAFile,
-		InsecureSkipTLSverify: chartRepo.AllowInsecureConnection,
+		Name: chartRepo.Name,
+		URL:  chartRepo.Url,
 	}
-	helmRepo, err := repo.NewChartRepository(helmRepoConfig, getter.All(&cli.EnvSettings{}))
+	helmRepo, err := repo.NewChartRepository(helmRepoConfig, chartRepoGetter.Providers())
 
 	if err != nil {
 		return nil, err
@@ -73,62 +74,99 @@ func (impl *HelmRepoManagerImpl) LoadIndexFile(chartRepo *sql.ChartRepo) (*repo.
 	return index, nil
 }
 
-func (impl *HelmRepoManagerImpl) ValuesJson(repoUrl string, version *repo.ChartVersion, username string, password string, allowInsecureConnection bool) (rawValues string, readme string, valuesSchemaJson string, notes string, err error) {
-	absoluteChartURL, err := repo.ResolveReferenceURL(repoUrl, version.URLs[0])
+func (impl *HelmRepoManagerImpl) ValuesJson(chartRepo *sql.ChartRepo, version *repo.ChartVersion, chartRepoGetter *util.ChartRepoGetter, registryClientGetter RegistryClientGetter) (chartData ChartData, err error) {
+	chartUrls, err := getChartUrls(chartRepo, version)
 	if err != nil {
-		return "", "", "", "", fmt.Errorf("failed to parse %s as URL: %v", repoUrl, err)
+		return ChartData{}, err
 	}
-
-	var byteBuffer *bytes.Buffer
-	if len(username) > 0 && len(password) > 0 {
-		byteBuffer, err = util.GetFromPrivateUrlWithRetry(repoUrl, absoluteChartURL, username, password, allowInsecureConnection)
-	} else {
-		byteBuffer, err = util.GetFromPublicUrlWithRetry(absoluteChartURL)
+	// urls are tried in order, the urls listed in the index first and then the mirrors of the chart repo
+	for _, chartUrl := range chartUrls {
+		chartData, err = impl.chartUrlValuesJson(chartUrl, version, chartRepoGetter, registryClientGetter)
+		if err == nil {
+			chartData.ChartArchiveUrl = chartUrl
+			return chartData, nil
+		}
+		impl.Logger.Warnw("error in getting chart from url, trying next url", "chartName", version.Name, "chartVersion", version.Version, "chartUrl", chartUrl, "err", err)
 	}
+	return ChartData{}, err
+}
 
-	if err != nil {
-		fmt.Println("err", err)
-		return "", "", "", "", err
+// getChartUrls returns the absolute urls of the chart archive listed in the index followed by the same archive on every
+// mirror of the chart repo. Archives are looked up on mirrors by their path relative to the chart repo, or by file name
+// when the index has an absolute url.
+func getChartUrls(chartRepo *sql.ChartRepo, version *repo.ChartVersion) ([]string, error) {
+	if len(version.URLs) == 0 {
+		return nil, fmt.Errorf("no urls found for chart %s version %s", version.Name, version.Version)
 	}
-	chart, err := loader.LoadArchive(byteBuffer)
-	if err != nil {
-		fmt.Println("err", err)
-		return "", "", "", "", err
-	}
-
-	// get values.yaml
-	rawFiles := chart.Raw
-	for _, f := range rawFiles {
-		if strings.EqualFold(f.Name, "values.yaml") {
-			rawValues = string(f.Data)
-			break
+	var chartUrls, mirrorChartUrls []string
+	for _, versionUrl := range version.URLs {
+		absoluteChartURL, err := repo.ResolveReferenceURL(chartRepo.Url, versionUrl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s as URL: %v", chartRepo.Url, err)
 		}
+		chartUrls = append(chartUrls, absoluteChartURL)
 	}
-
-	// get readme
-	files := chart.Files
-	for _, f := range files {
-		if strings.EqualFold(f.Name, "README.md") {
-			readme = string(f.Data)
-			break
+	for _, mirrorUrl := range extractMirrorUrls(chartRepo.MirrorUrls) {
+		for _, versionUrl := range version.URLs {
+			if registry.IsOCI(versionUrl) {
+				continue
+			}
+			parsedVersionUrl, err := url.Parse(versionUrl)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s as URL: %v", versionUrl, err)
+			}
+			mirrorPath := versionUrl
+			if parsedVersionUrl.IsAbs() {
+				mirrorPath = path.Base(parsedVersionUrl.Path)
+			}
+			mirrorChartUrl, err := repo.ResolveReferenceURL(mirrorUrl, mirrorPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s as URL: %v", mirrorUrl, err)
+			}
+			mirrorChartUrls = append(mirrorChartUrls, mirrorChartUrl)
 		}
 	}
+	return append(chartUrls, mirrorChartUrls...), nil
+}
 
-	// get notes
-	for _, templateFile := range chart.Templates {
-		if strings.EqualFold(templateFile.Name, "NOTES.txt") {
-			notes = string(templateFile.Data)
-			break
+func extractMirrorUrls(mirrorUrls string) []string {
+	mirrorUrlList := make([]string, 0)
+	for _, mirrorUrl := range strings.Split(mirrorUrls, ",") {
+		mirrorUrl = strings.TrimSpace(mirrorUrl)
+		if len(mirrorUrl) > 0 {
+			mirrorUrlList = append(mirrorUrlList, mirrorUrl)
 		}
 	}
+	return mirrorUrlList
+}
 
-	return rawValues, readme, string(chart.Schema), notes, err
+func (impl *HelmRepoManagerImpl) chartUrlValuesJson(chartUrl string, version *repo.ChartVersion, chartRepoGetter *util.ChartRepoGetter, registryClientGetter RegistryClientGetter) (ChartData, error) {
+	if registry.IsOCI(chartUrl) {
+		// index entries can point to charts pushed to an OCI registry
+		return impl.ociChartUrlValuesJson(chartUrl, version, registryClientGetter)
+	}
+	byteBuffer, err := util.GetFromUrlWithRetry(chartRepoGetter, chartUrl)
+	if err != nil {
+		return ChartData{}, err
+	}
+	chart, err := loader.LoadArchive(byteBuffer)
+	if err != nil {
+		return ChartData{}, err
+	}
+	return getChartData(chart), nil
 }
+
 func (impl *HelmRepoManagerImpl) OCIRepoValuesJson(client *registry.Client, registryUrl, chartName, version string) (chartData ChartData, err error) {
 	chart, digest, err := impl.LoadChartFromOCIRepo(client, registryUrl, chartName, version)
 	if err != nil {
 		return ChartData{}, err
 	}
+	chartData = getChartData(chart)
+	chartData.Digest = digest
+	return chartData, err
+}
+
+func getChartData(chart *chart.Chart) (chartData ChartData) {
 	// get values.yaml
 	rawFiles := chart.Raw
 	for _, f := range rawFiles {
@@ -156,54 +194,125 @@ func (impl *HelmRepoManagerImpl) OCIRepoValuesJson(client *registry.Client, regi
 	}
 	chartData.MetaData = chart.Metadata
 	chartData.ValuesSchemaJson = string(chart.Schema)
-	chartData.Digest = digest
+	return chartData
+}
 
-	return chartData, err
+// ociChartUrlValuesJson pulls the chart referenced by an oci:// url of the form
+// oci://<registry host>/<chart path>[:<tag>|@<digest>], the chart version is used as tag when the url has neither.
+func (impl *HelmRepoManagerImpl) ociChartUrlValuesJson(chartUrl string, version *repo.ChartVersion, registryClientGetter RegistryClientGetter) (ChartData, error) {
+	reference, err := orasRegistry.ParseReference(strings.TrimPrefix(chartUrl, fmt.Sprintf("%s://", registry.OCIScheme)))
+	if err != nil {
+		return ChartData{}, fmt.Errorf("invalid OCI chart url %s: %w", chartUrl, err)
+	}
+	registryHost, chartName, tag := reference.Registry, reference.Repository, reference.Reference
+	if len(tag) == 0 {
+		tag = version.Version
+	}
+	client, err := registryClientGetter(registryHost)
+	if err != nil {
+		impl.Logger.Errorw("error in getting registry client for OCI chart url", "chartUrl", chartUrl, "err", err)
+		return ChartData{}, err
+	}
+	return impl.OCIRepoValuesJson(client, registryHost, chartName, tag)
 }
 
-// FetchOCIChartTagsList list down all tags in of the given repository without pagination.
-func (impl *HelmRepoManagerImpl) FetchOCIChartTagsList(settings *registry2.Settings, ociRepoURL string) ([]string, error) {
-	// Retrieve list of repository tags
-	client := settings.RegistryClient
-	tags, err := client.FetchAllTags(strings.TrimPrefix(ociRepoURL, fmt.Sprintf("%s://", registry.OCIScheme)))
-	if err != nil || len(tags) == 0 {
-		if err != nil {
-			err = fmt.Errorf("unable to locate any tags in provided repository: %s", ociRepoURL)
+// FetchOCIChartTagsList lists the tags of the given repository sorting after last, page by page. All tags are listed
+// when last is empty, in which case a repository without tags is an error.
+func (impl *HelmRepoManagerImpl) FetchOCIChartTagsList(ociClient *registry3.OCIClient, repository, last string, pageSize int) ([]string, error) {
+	tags, err := ociClient.Tags(context.Background(), repository, last, pageSize)
+	if err != nil || (len(tags) == 0 && len(last) == 0) {
+		if err == nil {
+			err = fmt.Errorf("unable to locate any tags in provided repository: %s", repository)
 		}
-		impl.Logger.Errorw("error in fetching repository tags, FetchOCIChartTagsList", "repo url", ociRepoURL, "err", err)
+		impl.Logger.Errorw("error in fetching repository tags, FetchOCIChartTagsList", "repository", repository, "last", last, "err", err)
 		return nil, err
 	}
 	return tags, nil
 }
 
-func (impl *HelmRepoManagerImpl) RegistryLogin(client *registry.Client, store *sql.DockerArtifactStore, username, password string) error {
-
-	var loginOptions []registry.LoginOption
-	loginOptions = append(loginOptions, registry.LoginOptBasicAuth(username, password))
-	loginOptions = append(loginOptions, registry.LoginOptInsecure(store.Connection == INSECURE_CONNETION_STRING))
-	if store.Connection == SECURE_WITH_CERT_STRING {
-		certificateFilePath, err := registry2.CreateCertificateFile(store.Id, store.Cert)
-		if err != nil {
-			impl.Logger.Errorw("error in creating certificate file path for registry", "registryName", store.Id, "err", err)
-			return err
+// FetchOCIChartManifest fetches the manifest of a chart tag and the Chart.yaml metadata from the config blob of the
+// manifest, without downloading the chart layer. ErrNotHelmChart is returned for artifacts which are not helm charts.
+func (impl *HelmRepoManagerImpl) FetchOCIChartManifest(ociClient *registry3.OCIClient, repository, tag string) (*OCIChartManifest, error) {
+	ctx := context.Background()
+	manifest, err := ociClient.FetchManifest(ctx, repository, tag)
+	if err != nil {
+		impl.Logger.Errorw("error in fetching manifest, FetchOCIChartManifest", "repository", repository, "tag", tag, "err", err)
+		return nil, err
+	}
+	if manifest.Config.MediaType != registry.ConfigMediaType {
+		return nil, ErrNotHelmChart
+	}
+	chartManifest := &OCIChartManifest{Manifest: manifest}
+	isChartLayerFound := false
+	for _, layer := range manifest.Layers {
+		if layer.MediaType == registry.ChartLayerMediaType || layer.MediaType == registry.LegacyChartLayerMediaType {
+			chartManifest.ChartLayer = layer
+			isChartLayerFound = true
+			break
 		}
-		loginOptions = append(loginOptions, registry.LoginOptTLSClientConfig("", "", certificateFilePath))
 	}
+	if !isChartLayerFound {
+		return nil, fmt.Errorf("manifest of %s:%s does not contain a layer with mediatype %s", repository, tag, registry.ChartLayerMediaType)
+	}
+	config, err := ociClient.FetchBlob(ctx, repository, manifest.Config)
+	if err != nil {
+		impl.Logger.Errorw("error in fetching chart config, FetchOCIChartManifest", "repository", repository, "tag", tag, "err", err)
+		return nil, err
+	}
+	chartManifest.MetaData = &chart.Metadata{}
+	err = json.Unmarshal(config, chartManifest.MetaData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chart config of %s:%s: %w", repository, tag, err)
+	}
+	chartManifest.Created = getOCIChartCreated(manifest, chartManifest.MetaData, config)
+	return chartManifest, nil
+}
 
-	err := client.Login(store.RegistryURL,
-		loginOptions...,
-	)
+// getOCIChartCreated returns the creation time of a chart from the created annotation of the manifest, set by helm push,
+// falling back to the same annotation in Chart.yaml, the created field of the config and the last modified time the
+// registry reported for the manifest. Zero time is returned when none of them is set.
+func getOCIChartCreated(manifest *registry3.Manifest, metadata *chart.Metadata, config []byte) time.Time {
+	if created, err := time.Parse(time.RFC3339, manifest.Annotations[ocispec.AnnotationCreated]); err == nil {
+		return created
+	}
+	if created, err := time.Parse(time.RFC3339, metadata.Annotations[ocispec.AnnotationCreated]); err == nil {
+		return created
+	}
+	var configCreated struct {
+		Created time.Time `json:"created"`
+	}
+	if err := json.Unmarshal(config, &configCreated); err == nil && !configCreated.Created.IsZero() {
+		return configCreated.Created
+	}
+	return manifest.LastModified
+}
+
+// LoadOCIChartContent downloads the chart layer of the manifest for the values, README, NOTES and schema of the chart
+func (impl *HelmRepoManagerImpl) LoadOCIChartContent(ociClient *registry3.OCIClient, repository string, chartManifest *OCIChartManifest) (ChartData, error) {
+	chartArchive, err := ociClient.FetchBlob(context.Background(), repository, chartManifest.ChartLayer)
 	if err != nil {
-		impl.Logger.Errorw("error in registry login, RegistryLogin", "DockerArtifactStoreId", store.Id, "err", err)
-		return err
+		impl.Logger.Errorw("error in fetching chart layer, LoadOCIChartContent", "repository", repository, "digest", chartManifest.ChartLayer.Digest, "err", err)
+		return ChartData{}, err
 	}
-	return nil
+	chart, err := loader.LoadArchive(bytes.NewBuffer(chartArchive))
+	if err != nil {
+		impl.Logger.Errorw("error in loading chart bytes, LoadOCIChartContent", "repository", repository, "digest", chartManifest.ChartLayer.Digest, "err", err)
+		return ChartData{}, err
+	}
+	chartData := getChartData(chart)
+	chartData.MetaData = chartManifest.MetaData
+	chartData.Digest = chartManifest.ChartLayer.Digest.String()
+	chartData.Created = chartManifest.Created
+	return chartData, nil
 }
 
 func (impl *HelmRepoManagerImpl) LoadChartFromOCIRepo(client *registry.Client, registryUrl, chartname, version string) (*chart.Chart, string, error) {
-	ref := fmt.Sprintf("%s:%s",
-		path.Join(TrimSchemeFromURL(registryUrl), chartname),
-		version)
+	// tags never contain a colon, digests like sha256:<hex> always do
+	separator := ":"
+	if strings.Contains(version, ":") {
+		separator = "@"
+	}
+	ref := path.Join(TrimSchemeFromURL(registryUrl), chartname) + separator + version
 	chartDetails, err := client.Pull(
 		ref,
 		registry.PullOptWithChart(true),
@@ -1,29 +1,37 @@
 package pkg
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/Masterminds/semver/v3"
 	"github.com/devtron-labs/chart-sync/internals"
+	logger2 "github.com/devtron-labs/chart-sync/internals/logger"
 	"github.com/devtron-labs/chart-sync/internals/sql"
+	"github.com/devtron-labs/chart-sync/pkg/provider"
 	registry2 "github.com/devtron-labs/chart-sync/pkg/registry"
+	"github.com/devtron-labs/chart-sync/pkg/secret"
 	"github.com/devtron-labs/chart-sync/util"
 	registry3 "github.com/devtron-labs/common-lib/helmLib/registry"
 	"github.com/ghodss/yaml"
 	"github.com/go-pg/pg"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
 	"golang.org/x/exp/slices"
+	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/registry"
 	"helm.sh/helm/v3/pkg/repo"
 	url2 "net/url"
+	"path"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
 type SyncService interface {
-	Sync() (interface{}, error)
+	Sync() (*SyncReport, error)
 }
 
 type SyncServiceImpl struct {
@@ -35,7 +43,10 @@ type SyncServiceImpl struct {
 	appStoreRepository                   sql.AppStoreRepository
 	appStoreApplicationVersionRepository sql.AppStoreApplicationVersionRepository
 	configuration                        *internals.Configuration
-	registrySettings                     registry3.SettingsFactory
+	credentialProvider                   registry2.CredentialProvider
+	remoteConnectionRepository           sql.RemoteConnectionRepository
+	secretResolver                       secret.SecretResolver
+	providerService                      provider.ProviderService
 	mutex                                sync.Mutex
 }
 
@@ -47,60 +58,33 @@ func NewSyncServiceImpl(chartRepoRepository sql.ChartRepoRepository,
 	appStoreRepository sql.AppStoreRepository,
 	appStoreApplicationVersionRepository sql.AppStoreApplicationVersionRepository,
 	configuration *internals.Configuration,
-	registrySettings registry3.SettingsFactory,
+	credentialProvider registry2.CredentialProvider,
+	remoteConnectionRepository sql.RemoteConnectionRepository,
+	secretResolver secret.SecretResolver,
+	providerService provider.ProviderService,
 ) *SyncServiceImpl {
 	return &SyncServiceImpl{
 		chartRepoRepository:                  chartRepoRepository,
-		logger:                               logger,
+		logger:                               logger.Named(logger2.ComponentSync),
 		helmRepoManager:                      helmRepoManager,
 		dockerArtifactStoreRepository:        dockerArtifactStoreRepository,
 		ociRegistryConfigRepository:          ociRegistryConfigRepository,
 		appStoreRepository:                   appStoreRepository,
 		appStoreApplicationVersionRepository: appStoreApplicationVersionRepository,
 		configuration:                        configuration,
-		registrySettings:                     registrySettings,
+		credentialProvider:                   credentialProvider,
+		remoteConnectionRepository:           remoteConnectionRepository,
+		secretResolver:                       secretResolver,
+		providerService:                      providerService,
 	}
 }
 
-func (impl *SyncServiceImpl) Sync() (interface{}, error) {
-	var (
-		err           error
-		repos         []*sql.ChartRepo
-		repo          *sql.ChartRepo
-		chartRepoId   int
-		ociRegistries []*sql.DockerArtifactStore
-		ociRegistry   *sql.DockerArtifactStore
-	)
-	if impl.configuration.ChartProviderId == "*" {
-		ociRegistries, err = impl.dockerArtifactStoreRepository.FindAllChartProviders()
-		if err != nil {
-			impl.logger.Errorw("err in getting OCI Registries list", "err", err)
-		}
-		repos, err = impl.chartRepoRepository.GetAll()
-		if err != nil {
-			impl.logger.Errorw("err in getting repo list", "err", err)
-		}
-	} else {
-		if impl.configuration.IsOCIRegistry {
-			ociRegistry, err = impl.dockerArtifactStoreRepository.FindOne(impl.configuration.ChartProviderId)
-			if err != nil {
-				impl.logger.Errorw("err in getting OCI Registries list", "err", err)
-				return nil, err
-			}
-			ociRegistries = []*sql.DockerArtifactStore{ociRegistry}
-		} else {
-			chartRepoId, err = strconv.Atoi(impl.configuration.ChartProviderId)
-			if err != nil {
-				impl.logger.Errorw("err in parsing ChartProviderId", "err", err)
-				return nil, err
-			}
-			repo, err = impl.chartRepoRepository.FindById(chartRepoId)
-			if err != nil {
-				impl.logger.Errorw("err in getting repo list", "err", err)
-				return nil, err
-			}
-			repos = []*sql.ChartRepo{repo}
-		}
+func (impl *SyncServiceImpl) Sync() (*SyncReport, error) {
+	report := &SyncReport{}
+	repos, ociRegistries, err := impl.providerService.GetChartProviders()
+	if err != nil {
+		impl.logger.Errorw("error in getting chart providers", "err", err)
+		return nil, err
 	}
 	for _, registryObj := range ociRegistries {
 		// validation to avoid nil pointer
@@ -108,176 +92,581 @@ func (impl *SyncServiceImpl) Sync() (interface{}, error) {
 			impl.logger.Errorw("no valid configuration found for OCI registry", "OCI registry", registryObj.Id)
 			continue
 		}
+		if !impl.providerService.IsSyncDue(provider.OCIRegistryKey(registryObj)) {
+			impl.logger.Debugw("skipping registry not due as per its schedule", "OCI Registry Id", registryObj.Id)
+			continue
+		}
+		report.ExpiringCertificates = append(report.ExpiringCertificates, impl.getExpiringCertificates(registryObj)...)
+		err = impl.secretResolver.ResolveDockerArtifactStore(registryObj)
+		if err != nil {
+			continue
+		}
 		impl.logger.Infow("syncing repo", "OCI Registry Id", registryObj.Id)
 		err := impl.syncOCIRepo(registryObj)
 		if err != nil {
-			impl.logger.Errorw("repo sync error", "OCIRegistry", registryObj)
+			impl.logger.Errorw("repo sync error", "OCIRegistry", registryObj.LogSafe(), "err", err)
 		}
 	}
 	for _, repository := range repos {
+		if !impl.providerService.IsSyncDue(provider.ChartRepoKey(repository)) {
+			impl.logger.Debugw("skipping repo not due as per its schedule", "name", repository.Name)
+			continue
+		}
 		impl.logger.Infow("syncing repo", "name", repository.Name)
 		err := impl.syncRepo(repository)
 		if err != nil {
-			impl.logger.Errorw("repo sync error", "repo", repository)
+			impl.logger.Errorw("repo sync error", "repo", repository.LogSafe(), "err", err)
 		}
 	}
-	return nil, nil
+	return report, nil
+}
+
+// getExpiringCertificates returns the CA and client certificates of the store which have expired or expire within the
+// configured warning period. Certificates which can not be parsed are logged, the sync of the store fails on them anyway.
+func (impl *SyncServiceImpl) getExpiringCertificates(store *sql.DockerArtifactStore) []*ExpiringCertificate {
+	certificates := make(map[string]string)
+	if store.Connection == registry3.SECURE_WITH_CERT && len(store.Cert) > 0 {
+		certificates[CertificateTypeCA] = store.Cert
+	}
+	if len(store.ClientCert) > 0 {
+		certificates[CertificateTypeClient] = store.ClientCert
+	}
+	warnAfter := time.Now().AddDate(0, 0, impl.configuration.CertificateExpiryWarningInDays)
+	var expiringCertificates []*ExpiringCertificate
+	for certificateType, certificate := range certificates {
+		notAfter, err := registry2.GetCertificateExpiry(certificate)
+		if err != nil {
+			impl.logger.Errorw("error in parsing certificate of registry", "registryName", store.Id, "certificateType", certificateType, "err", err)
+			continue
+		}
+		if notAfter.After(warnAfter) {
+			continue
+		}
+		expiringCertificates = append(expiringCertificates, &ExpiringCertificate{
+			DockerArtifactStoreId: store.Id,
+			CertificateType:       certificateType,
+			NotAfter:              notAfter,
+			IsExpired:             notAfter.Before(time.Now()),
+		})
+	}
+	return expiringCertificates
 }
 
 func extractChartRepoRepositoryList(repositoryList string) []string {
 	chartNameList := make([]string, 0)
 	chartRepoRepositoryList := strings.Split(repositoryList, ",")
 	for _, chartName := range chartRepoRepositoryList {
-		chartNameList = append(chartNameList, strings.TrimSpace(chartName))
+		chartName = strings.TrimSpace(chartName)
+		if len(chartName) > 0 {
+			chartNameList = append(chartNameList, chartName)
+		}
 	}
 	return chartNameList
 }
 
+func parseRegistryUrl(registryUrl string) (*url2.URL, error) {
+	if !strings.Contains(strings.ToLower(registryUrl), "https") && !strings.Contains(strings.ToLower(registryUrl), "http") {
+		return url2.Parse(fmt.Sprintf("//%s", registryUrl))
+	}
+	return url2.Parse(registryUrl)
+}
+
+// discoverChartRepositories lists the repositories of the registry under the registry url path using the catalog endpoint.
+// When discovery is enabled repositories starting with the discovery prefix are returned, along with the repositories
+// matching any of the include patterns of RepositoryList. Returned names are relative to the registry url path, like the
+// entries of RepositoryList. Repositories which are not in app store yet are only returned when they hold helm charts.
+func (impl *SyncServiceImpl) discoverChartRepositories(ociClient *registry2.OCIClient, ociRepo *sql.DockerArtifactStore, ociRegistryConfig *sql.OCIRegistryConfig, includePatterns []string, applicationByPath map[string]*sql.AppStore) ([]string, error) {
+	url, err := parseRegistryUrl(ociRepo.RegistryURL)
+	if err != nil {
+		impl.logger.Errorw("registry url parse err", "registryURL", ociRepo.RegistryURL, "err", err)
+		return nil, err
+	}
+	registryUrlPath := strings.Trim(strings.TrimSpace(url.Path), "/")
+	discoveryPrefix := strings.TrimPrefix(strings.TrimSpace(ociRegistryConfig.RepositoryDiscoveryPrefix), "/")
+	catalogPrefix := ""
+	if len(includePatterns) == 0 {
+		// the catalog is filtered on the discovery prefix only when no pattern needs to be matched
+		catalogPrefix = discoveryPrefix
+	}
+	if len(registryUrlPath) > 0 {
+		catalogPrefix = registryUrlPath + "/" + catalogPrefix
+	}
+	ctx := context.Background()
+	repositories, err := ociClient.Catalog(ctx, catalogPrefix)
+	if err != nil {
+		return nil, err
+	}
+	chartNameList := make([]string, 0)
+	for _, repository := range repositories {
+		chartName := repository
+		if len(registryUrlPath) > 0 {
+			chartName = strings.TrimPrefix(repository, registryUrlPath+"/")
+		}
+		isDiscovered := ociRegistryConfig.IsRepositoryDiscoveryEnabled && strings.HasPrefix(chartName, discoveryPrefix)
+		if !isDiscovered && !util.MatchAnyRepositoryPattern(includePatterns, chartName) {
+			continue
+		}
+		if _, ok := applicationByPath[chartName]; !ok {
+			isHelmChart, err := ociClient.IsHelmChartRepository(ctx, repository)
+			if err != nil {
+				impl.logger.Warnw("error in checking repository for helm charts, skipping", "registryName", ociRepo.Id, "repository", repository, "err", err)
+				continue
+			}
+			if !isHelmChart {
+				impl.logger.Debugw("skipping repository without helm charts", "registryName", ociRepo.Id, "repository", repository)
+				continue
+			}
+		}
+		chartNameList = append(chartNameList, chartName)
+	}
+	impl.logger.Infow("discovered chart repositories of registry", "registryName", ociRepo.Id, "prefix", catalogPrefix, "repositories", len(repositories), "charts", len(chartNameList))
+	return chartNameList, nil
+}
+
+// ociChartSource is a chart pull config of an OCI registry along with its client and the charts to be synced with it
+type ociChartSource struct {
+	ociRegistryConfig *sql.OCIRegistryConfig
+	ociClient         *registry2.OCIClient
+	chartNames        []string
+}
+
+// syncOCIRepo syncs the charts of every chart pull config of the registry, each with its own credentials and visibility.
+// Charts listed in several configs are synced with the first of them.
 func (impl *SyncServiceImpl) syncOCIRepo(ociRepo *sql.DockerArtifactStore) error {
 	applications, err := impl.appStoreRepository.FindByStoreId(ociRepo.Id)
 	if err != nil {
 		impl.logger.Errorw("error in fetching app for repo", "OCI registry", ociRepo.Id, "err", err)
 		return nil
 	}
-	applicationId := make(map[string]int)
-	// Already validated for nil pointer
-	chartRepoRepositoryList := extractChartRepoRepositoryList(ociRepo.OCIRegistryConfig[0].RepositoryList)
+	applicationByPath := make(map[string]*sql.AppStore)
+	for _, application := range applications {
+		applicationByPath[application.GetRepositoryPath()] = application
+	}
+	var chartSources []*ociChartSource
+	var isAnyChartSourceFailed bool
+	chartRepoRepositoryList := make([]string, 0)
+	for _, ociRegistryConfig := range util.GetChartPullConfigs(ociRepo) {
+		registryConfig, err := registry2.NewToRegistryConfig(ociRepo, ociRegistryConfig)
+		if err != nil {
+			impl.logger.Errorw("error in getting registry config", "registryName", ociRepo.Id, "ociRegistryConfigId", ociRegistryConfig.Id, "err", err)
+			isAnyChartSourceFailed = true
+			continue
+		}
+		chartSource, err := impl.getOCIChartSource(ociRepo, ociRegistryConfig, registryConfig, applicationByPath)
+		if err != nil {
+			impl.logger.Errorw("error in listing charts of registry config", "registryName", ociRepo.Id, "ociRegistryConfigId", ociRegistryConfig.Id, "err", err)
+			isAnyChartSourceFailed = true
+			continue
+		}
+		chartSources = append(chartSources, chartSource)
+		for _, chartName := range chartSource.chartNames {
+			if !slices.Contains(chartRepoRepositoryList, chartName) {
+				chartRepoRepositoryList = append(chartRepoRepositoryList, chartName)
+			}
+		}
+	}
+	if len(chartSources) == 0 {
+		return fmt.Errorf("no chart pull config of registry %s could be synced", ociRepo.Id)
+	}
+
+	// charts are not removed from app store when the charts of any config could not be listed
 	removedApplicationList := make([]*sql.AppStore, 0)
 	for _, application := range applications {
-		if !slices.Contains(chartRepoRepositoryList, application.Name) {
+		if !isAnyChartSourceFailed && !slices.Contains(chartRepoRepositoryList, application.GetRepositoryPath()) {
 			application.Active = false
 			application.UpdatedOn = time.Now()
 			removedApplicationList = append(removedApplicationList, application)
 		}
-		applicationId[application.Name] = application.Id
 	}
 
 	if len(removedApplicationList) > 0 {
-		impl.logger.Errorw("removing in charts from app store", "RemovedApplicationList", removedApplicationList, "err", err)
+		removedAppStoreIds := make([]int, 0, len(removedApplicationList))
+		for _, application := range removedApplicationList {
+			removedAppStoreIds = append(removedAppStoreIds, application.Id)
+		}
+		// only ids are logged, as the app stores hold their chart repo with its credentials
+		impl.logger.Infow("removing charts from app store which are no longer in the registry", "registryName", ociRepo.Id, "appStoreIds", removedAppStoreIds)
 		err = impl.appStoreRepository.Update(removedApplicationList)
 		if err != nil {
 			impl.logger.Errorw("error in updating app store", "err", err)
 			return nil
 		}
 	}
-	registryConfig, err := registry2.NewToRegistryConfig(ociRepo)
-	defer func() {
-		if registryConfig != nil {
-			err := registry3.DeleteCertificateFolder(registryConfig.RegistryCAFilePath)
-			if err != nil {
-				impl.logger.Errorw("error in deleting certificate folder", "registryName", registryConfig.RegistryId, "err", err)
+
+	url, err := parseRegistryUrl(ociRepo.RegistryURL)
+	if err != nil {
+		impl.logger.Errorw("registry url parse err", "registryURL", ociRepo.RegistryURL, "err", err)
+		return err
+	}
+	syncedChartNames := make(map[string]bool)
+	for _, chartSource := range chartSources {
+		for _, chartName := range chartSource.chartNames {
+			if syncedChartNames[chartName] {
+				continue
 			}
+			syncedChartNames[chartName] = true
+			impl.syncOCIChart(ociRepo, chartSource, url.Path, chartName, applicationByPath)
 		}
-	}()
+	}
+	return nil
+}
+
+// getOCIChartSource creates the client of a chart pull config of the registry and lists the charts to be synced with it
+func (impl *SyncServiceImpl) getOCIChartSource(ociRepo *sql.DockerArtifactStore, ociRegistryConfig *sql.OCIRegistryConfig, registryConfig *registry3.Configuration, applicationByPath map[string]*sql.AppStore) (*ociChartSource, error) {
+	err := impl.setRegistryCredential(registryConfig)
 	if err != nil {
-		impl.logger.Errorw("error in getting registry config", "registryName", registryConfig.RegistryId, "err", err)
-		return nil
+		impl.logger.Errorw("error in getting credential of registry", "registryName", ociRepo.Id, "err", err)
+		return nil, err
 	}
-	settingsGetter, err := impl.registrySettings.GetSettings(registryConfig)
+	impl.warnPlainHttpConnection(ociRepo)
+	httpClient, err := registry2.GetHttpClient(ociRepo)
 	if err != nil {
-		impl.logger.Errorw("error in getting registry settings", "registryName", registryConfig.RegistryId, "err", err)
-		return nil
+		impl.logger.Errorw("error in getting http client for registry", "registryName", ociRepo.Id, "err", err)
+		return nil, err
 	}
-	settings, err := settingsGetter.GetRegistrySettings(registryConfig)
+	ociClient := registry2.NewOCIClient(registryConfig, httpClient)
+	_, err = util.NewVersionFilter(ociRegistryConfig.VersionFilter)
 	if err != nil {
-		impl.logger.Errorw("error in getting registry settings for registry", "registryName", ociRepo.Id, "err", err)
-		return err
+		impl.logger.Errorw("error in parsing version filter of registry", "registryName", ociRepo.Id, "err", err)
+		return nil, err
+	}
+	chartRepoRepositoryList, includePatterns, excludePatterns := util.SplitRepositoryPatterns(extractChartRepoRepositoryList(ociRegistryConfig.RepositoryList))
+	if ociRegistryConfig.IsRepositoryDiscoveryEnabled || len(includePatterns) > 0 {
+		discoveredRepositoryList, err := impl.discoverChartRepositories(ociClient, ociRepo, ociRegistryConfig, includePatterns, applicationByPath)
+		if err != nil {
+			impl.logger.Errorw("error in discovering repositories of registry", "registryName", ociRepo.Id, "err", err)
+			return nil, err
+		}
+		for _, chartName := range discoveredRepositoryList {
+			if !slices.Contains(chartRepoRepositoryList, chartName) {
+				chartRepoRepositoryList = append(chartRepoRepositoryList, chartName)
+			}
+		}
 	}
-	client := settings.RegistryClient
-	ociRepo.RegistryURL = settings.RegistryHostURL
+	if len(excludePatterns) > 0 {
+		chartRepoRepositoryList = slices.DeleteFunc(chartRepoRepositoryList, func(chartName string) bool {
+			return util.MatchAnyRepositoryPattern(excludePatterns, chartName)
+		})
+	}
+	return &ociChartSource{
+		ociRegistryConfig: ociRegistryConfig,
+		ociClient:         ociClient,
+		chartNames:        chartRepoRepositoryList,
+	}, nil
+}
 
-	for _, chartName := range chartRepoRepositoryList {
-		var url *url2.URL
-		if !strings.Contains(strings.ToLower(ociRepo.RegistryURL), "https") && !strings.Contains(strings.ToLower(ociRepo.RegistryURL), "http") {
-			url, err = url2.Parse(fmt.Sprintf("//%s", ociRepo.RegistryURL))
+// syncOCIChart syncs the new tags of the chart in the repository at repositoryPath of the registry, creating the app store
+// entry of the chart if needed. Repository path is the identity of the app, the chart name is taken from Chart.yaml.
+func (impl *SyncServiceImpl) syncOCIChart(ociRepo *sql.DockerArtifactStore, chartSource *ociChartSource, registryUrlPath, repositoryPath string, applicationByPath map[string]*sql.AppStore) {
+	ociClient := chartSource.ociClient
+	parsedUrlPath := strings.TrimSpace(registryUrlPath)
+	parsedRepoName := strings.TrimSpace(repositoryPath)
+	// Join handles empty strings
+	repository := strings.TrimPrefix(filepath.Join(parsedUrlPath, parsedRepoName), "/")
+
+	app, ok := applicationByPath[repositoryPath]
+	isFullTagList := !ok || impl.isFullTagListDue(app)
+	tagListCursor := ""
+	if !isFullTagList {
+		tagListCursor = app.TagListCursor
+	}
+	chartVersions, err := impl.helmRepoManager.FetchOCIChartTagsList(ociClient, repository, tagListCursor, impl.configuration.OCITagListPageSize)
+	if err != nil {
+		impl.logger.Errorw("error in fetching OCI repository tags", "repository", repository, "err", err)
+		return
+	}
+	if !isFullTagList && slices.ContainsFunc(chartVersions, func(tag string) bool { return tag <= tagListCursor }) {
+		// the registry ignores the last parameter and listed all tags
+		isFullTagList = true
+	}
+	if !isFullTagList && len(chartVersions) == 0 {
+		impl.logger.Debugw("no new tags in repository", "registryName", ociRepo.Id, "repositoryPath", repositoryPath, "tagListCursor", tagListCursor)
+		return
+	}
+
+	if !ok {
+		var fetchErr error
+		app, fetchErr = impl.appStoreRepository.FindInactiveOneByRepositoryPath(ociRepo.Id, repositoryPath)
+		if fetchErr == nil {
+			app.Active = true
+			app.UpdatedOn = time.Now()
+			err = impl.appStoreRepository.Update([]*sql.AppStore{app})
+			if err != nil {
+				impl.logger.Errorw("error in updating app store", "err", err)
+				return
+			}
+		} else if fetchErr == pg.ErrNoRows {
+			//create new app in AppStore
+			app = &sql.AppStore{
+				Name:                  repositoryPath,
+				RepositoryPath:        repositoryPath,
+				DisplayName:           getOCIChartDisplayName(repositoryPath),
+				DockerArtifactStoreId: ociRepo.Id,
+				CreatedOn:             time.Now(),
+				UpdatedOn:             time.Now(),
+				Active:                true,
+			}
+			err = impl.appStoreRepository.Save(app)
+			if err != nil {
+				impl.logger.Errorw("error in saving app", "app", app, "err", err)
+				return
+			}
 		} else {
-			url, err = url2.Parse(ociRepo.RegistryURL)
+			return
 		}
-
+		applicationByPath[repositoryPath] = app
+	}
+	if len(app.RepositoryPath) == 0 {
+		// apps synced before the repository path was stored
+		app.RepositoryPath = repositoryPath
+		app.DisplayName = getOCIChartDisplayName(repositoryPath)
+	}
+	id := app.Id
+	versionFilter, err := util.NewVersionFilter(chartSource.ociRegistryConfig.VersionFilter, app.VersionFilter)
+	if err != nil {
+		impl.logger.Errorw("error in parsing version filter of chart", "registryName", ociRepo.Id, "repositoryPath", repositoryPath, "err", err)
+		return
+	}
+	if isFullTagList {
+		// before the new versions are fetched, so that deleted tags do not count as the latest versions to keep
+		err = impl.deprecateDeletedChartVersions(id, chartVersions)
 		if err != nil {
-			impl.logger.Errorw("registry url parse err", "registryURL", ociRepo.RegistryURL, "err", err)
-			return err
+			impl.logger.Errorw("error in deprecating chart versions deleted from registry", "appId", id, "err", err)
+			return
 		}
-		parsedUrlPath := strings.TrimSpace(url.Path)
-		parsedHost := strings.TrimSpace(url.Host)
-		parsedRepoName := strings.TrimSpace(chartName)
-		// Join handles empty strings
-		ref := filepath.Join(parsedHost, parsedUrlPath, parsedRepoName)
-		var chartVersions []string
-
-		chartVersions, err = impl.helmRepoManager.FetchOCIChartTagsList(settings, ref)
+	}
+	//update entries if any  id, chartVersions
+	impl.logger.Infow("handling all versions of chart", "registryName", ociRepo.Id, "repositoryPath", repositoryPath, "chartVersions", len(chartVersions))
+	if impl.configuration.ParallelismLimitForTagProcessing == 0 {
+		err = impl.updateOCIRegistryChartVersions(ociClient, id, chartVersions, repository, versionFilter)
+	} else {
+		err = impl.updateOCIRegistryChartVersionsV2(ociClient, id, chartVersions, repository, versionFilter)
+	}
+	if err != nil {
+		impl.logger.Errorw("error in updating chart versions", "err", err, "appId", id)
+		return
+	}
+	if isFullTagList {
+		err = impl.backfillOCIChartVersions(ociClient, id, repository)
 		if err != nil {
-			impl.logger.Errorw("error in fetching OCI repository tags", "repository url", ref, "err", err)
-			continue
+			impl.logger.Errorw("error in backfilling creation time and chart yaml of chart versions", "appId", id, "err", err)
+			return
+		}
+		err = impl.backfillChartVersionsMetadata(id)
+		if err != nil {
+			impl.logger.Errorw("error in backfilling metadata of chart versions", "appId", id, "err", err)
+			return
 		}
+	}
+	err = impl.updateOCIAppStore(app, chartVersions, isFullTagList)
+	if err != nil {
+		impl.logger.Errorw("error in updating app store", "appId", id, "err", err)
+	}
+}
 
-		id, ok := applicationId[chartName]
-		if !ok {
-			app, fetchErr := impl.appStoreRepository.FindInactiveOneByName(ociRepo.Id, chartName)
-			if fetchErr == nil {
-				app.Active = true
-				app.UpdatedOn = time.Now()
-				err = impl.appStoreRepository.Update([]*sql.AppStore{app})
-				if err != nil {
-					impl.logger.Errorw("error in updating app store", "err", err)
-					continue
-				}
-			} else if fetchErr == pg.ErrNoRows {
-				//create new app in AppStore
-				app = &sql.AppStore{
-					Name:                  chartName,
-					DockerArtifactStoreId: ociRepo.Id,
-					CreatedOn:             time.Now(),
-					UpdatedOn:             time.Now(),
-					Active:                true,
-				}
-				err = impl.appStoreRepository.Save(app)
-				if err != nil {
-					impl.logger.Errorw("error in saving app", "app", app, "err", err)
-					continue
-				}
-			} else {
-				continue
+// getOCIChartDisplayName returns the name of the repository qualified with its parent path for nested repositories,
+// like redis (team-a) for team-a/redis
+func getOCIChartDisplayName(repositoryPath string) string {
+	parentPath, repositoryName := path.Split(strings.Trim(repositoryPath, "/"))
+	if len(parentPath) == 0 {
+		return repositoryName
+	}
+	return fmt.Sprintf("%s (%s)", repositoryName, strings.TrimSuffix(parentPath, "/"))
+}
+
+// isFullTagListDue reports whether all tags of the repository of the app should be listed instead of the tags sorting
+// after its cursor. Either way new tags are the ones without a stored version, but the incremental listing misses
+// deleted tags and tags sorting lexically before the cursor, so all tags are listed on every run unless a full list
+// interval is configured.
+func (impl *SyncServiceImpl) isFullTagListDue(app *sql.AppStore) bool {
+	fullTagListInterval := time.Duration(impl.configuration.OCIFullTagListIntervalInHours) * time.Hour
+	if len(app.TagListCursor) == 0 || fullTagListInterval <= 0 {
+		return true
+	}
+	return time.Since(app.TagsFullyListedOn) >= fullTagListInterval
+}
+
+// updateOCIAppStore moves the tag list cursor of the app to the greatest tag listed and updates the chart name of the app
+// to the name in Chart.yaml of its latest version
+func (impl *SyncServiceImpl) updateOCIAppStore(app *sql.AppStore, tags []string, isFullTagList bool) error {
+	tagListCursor := app.TagListCursor
+	if isFullTagList {
+		tagListCursor = ""
+	}
+	for _, tag := range tags {
+		if tag > tagListCursor {
+			tagListCursor = tag
+		}
+	}
+	app.TagListCursor = tagListCursor
+	if isFullTagList {
+		app.TagsFullyListedOn = time.Now()
+	}
+	applicationVersions, err := impl.appStoreApplicationVersionRepository.FindVersionsByAppStoreId(app.Id)
+	if err != nil {
+		impl.logger.Errorw("error in getting application versions ", "err", err, "appId", app.Id)
+		return err
+	}
+	var latestVersion *sql.AppStoreApplicationVersion
+	for _, applicationVersion := range applicationVersions {
+		if len(applicationVersion.Name) > 0 && (latestVersion == nil || applicationVersion.Created.After(latestVersion.Created)) {
+			latestVersion = applicationVersion
+		}
+	}
+	if latestVersion != nil {
+		app.ChartName = latestVersion.Name
+	}
+	return impl.appStoreRepository.Update([]*sql.AppStore{app})
+}
+
+// backfillOCIChartVersions sets the creation time and the chart yaml of versions synced before they were recorded for
+// OCI charts from the manifest of their tags. Creation time falls back to the time the version was first synced.
+func (impl *SyncServiceImpl) backfillOCIChartVersions(ociClient *registry2.OCIClient, appId int, repository string) error {
+	applicationVersions, err := impl.appStoreApplicationVersionRepository.FindVersionsWithoutCreatedOrChartYaml(appId)
+	if err != nil {
+		impl.logger.Errorw("error in getting application versions ", "err", err, "appId", appId)
+		return err
+	}
+	versionsWithoutFirstSynced := make([]string, 0)
+	for _, applicationVersion := range applicationVersions {
+		if applicationVersion.Created.IsZero() && applicationVersion.CreatedOn.IsZero() {
+			versionsWithoutFirstSynced = append(versionsWithoutFirstSynced, applicationVersion.Version)
+		}
+	}
+	fallbackCreated := getOCIChartFallbackCreated(time.Now(), versionsWithoutFirstSynced)
+	backfilledVersions := make([]*sql.AppStoreApplicationVersion, 0)
+	for _, applicationVersion := range applicationVersions {
+		isCreatedMissing := applicationVersion.Created.IsZero()
+		// chart yaml of OCI charts used to be the tag as json string
+		isChartYamlMissing := !strings.HasPrefix(strings.TrimSpace(applicationVersion.ChartYaml), "{")
+		if !isCreatedMissing && !isChartYamlMissing {
+			continue
+		}
+		var chartManifest *OCIChartManifest
+		if !applicationVersion.Deprecated {
+			chartManifest, err = impl.helmRepoManager.FetchOCIChartManifest(ociClient, repository, applicationVersion.Version)
+			if err != nil {
+				impl.logger.Warnw("error in fetching manifest of chart version for backfill", "appId", appId, "version", applicationVersion.Version, "err", err)
+				chartManifest = nil
 			}
-			applicationId[chartName] = app.Id
-			id = app.Id
 		}
-		//update entries if any  id, chartVersions
-		impl.logger.Infow("handling all versions of chart", "registryName", ociRepo.Id, "chartName", chartName, "chartVersions", len(chartVersions))
-		if impl.configuration.ParallelismLimitForTagProcessing == 0 {
-			err = impl.updateOCIRegistryChartVersions(client, id, chartVersions, ociRepo, chartName)
-		} else {
-			err = impl.updateOCIRegistryChartVersionsV2(client, id, chartVersions, ociRepo, chartName)
+		if isCreatedMissing {
+			// the row was stored by the sync which first saw the version
+			applicationVersion.Created = applicationVersion.CreatedOn
+			if applicationVersion.CreatedOn.IsZero() {
+				applicationVersion.Created = fallbackCreated[applicationVersion.Version]
+			}
+			if chartManifest != nil && !chartManifest.Created.IsZero() {
+				applicationVersion.Created = chartManifest.Created
+			}
 		}
-		if err != nil {
-			impl.logger.Errorw("error in updating chart versions", "err", err, "appId", id)
+		if isChartYamlMissing && chartManifest != nil {
+			chartYaml, err := getOCIChartYaml(chartManifest.MetaData, ociClient.Reference(repository, applicationVersion.Version), chartManifest.ChartLayer.Digest.String(), applicationVersion.Created)
+			if err != nil {
+				impl.logger.Errorw("error in marshaling json", "err", err)
+				return err
+			}
+			applicationVersion.ChartYaml = string(chartYaml)
+		} else if !isCreatedMissing {
 			continue
 		}
+		applicationVersion.UpdatedOn = time.Now()
+		backfilledVersions = append(backfilledVersions, applicationVersion)
 	}
-	return nil
+	if len(backfilledVersions) == 0 {
+		return nil
+	}
+	impl.logger.Infow("backfilling creation time and chart yaml of chart versions", "appId", appId, "versions", len(backfilledVersions))
+	return impl.appStoreApplicationVersionRepository.Update(backfilledVersions)
+}
+
+// deprecateDeletedChartVersions marks the versions of the app whose tags are no longer in the registry as deprecated.
+// Versions are not deleted as installed apps still refer to them.
+func (impl *SyncServiceImpl) deprecateDeletedChartVersions(appId int, tags []string) error {
+	applicationVersions, err := impl.appStoreApplicationVersionRepository.FindVersionsByAppStoreId(appId)
+	if err != nil {
+		impl.logger.Errorw("error in getting application versions ", "err", err, "appId", appId)
+		return err
+	}
+	deletedVersions := make([]*sql.AppStoreApplicationVersion, 0)
+	for _, applicationVersion := range applicationVersions {
+		if applicationVersion.Deprecated || slices.Contains(tags, applicationVersion.Version) {
+			continue
+		}
+		applicationVersion.Deprecated = true
+		applicationVersion.UpdatedOn = time.Now()
+		deletedVersions = append(deletedVersions, applicationVersion)
+	}
+	if len(deletedVersions) == 0 {
+		return nil
+	}
+	impl.logger.Infow("deprecating chart versions deleted from registry", "appId", appId, "versions", len(deletedVersions))
+	return impl.appStoreApplicationVersionRepository.Update(deletedVersions)
 }
 
 func (impl *SyncServiceImpl) syncRepo(repo *sql.ChartRepo) error {
-	indexFile, err := impl.helmRepoManager.LoadIndexFile(repo)
+	var sshHostKeyCallback ssh.HostKeyCallback
+	if repo.RemoteConnectionConfigId > 0 {
+		remoteConnectionConfig, err := impl.remoteConnectionRepository.GetById(repo.RemoteConnectionConfigId)
+		if err != nil {
+			impl.logger.Errorw("error in getting remote connection config", "repo", repo.Name, "remoteConnectionConfigId", repo.RemoteConnectionConfigId, "err", err)
+			return err
+		}
+		// a deleted or missing config must not make the repo be reached directly
+		if remoteConnectionConfig.Id == 0 {
+			impl.logger.Errorw("remote connection config of repo not found", "repo", repo.Name, "remoteConnectionConfigId", repo.RemoteConnectionConfigId)
+			return fmt.Errorf("remote connection config %d of chart repo %s not found", repo.RemoteConnectionConfigId, repo.Name)
+		}
+		repo.RemoteConnectionConfig = remoteConnectionConfig
+		sshHostKeyCallback, err = util.NewSSHHostKeyCallback(impl.configuration.SSHKnownHostsFile, impl.configuration.SSHHostKeyFingerprints)
+		if err != nil {
+			impl.logger.Errorw("error in loading ssh host keys", "repo", repo.Name, "err", err)
+			return err
+		}
+	}
+	err := impl.secretResolver.ResolveChartRepo(repo)
+	if err != nil {
+		return err
+	}
+	chartRepoGetter, err := util.NewChartRepoGetter(repo, sshHostKeyCallback)
+	if err != nil {
+		impl.logger.Errorw("error in creating chart repo getter", "repo", repo.Name, "err", err)
+		return err
+	}
+	defer func() {
+		err := chartRepoGetter.Close()
+		if err != nil {
+			impl.logger.Errorw("error in closing chart repo connection", "repo", repo.Name, "err", err)
+		}
+	}()
+	registryClientGetter := impl.getRegistryClientGetter(repo, chartRepoGetter)
+	indexFile, err := impl.helmRepoManager.LoadIndexFile(repo, chartRepoGetter)
 	if err != nil {
 		impl.logger.Errorw("error in loading index file", "repo", repo.Name, "err", err)
 		return err
 	}
 	indexFile.SortEntries()
+	_, err = util.NewVersionFilter(repo.VersionFilter)
+	if err != nil {
+		impl.logger.Errorw("error in parsing version filter of repo", "repo", repo.Name, "err", err)
+		return err
+	}
 	applications, err := impl.appStoreRepository.FindByRepoId(repo.Id)
 	if err != nil {
 		impl.logger.Errorw("error in fetching app for repo", "repo", repo.Id, "err", err)
 	}
-	applicationId := make(map[string]int)
+	applicationByName := make(map[string]*sql.AppStore)
 	for _, application := range applications {
-		applicationId[application.Name] = application.Id
+		applicationByName[application.Name] = application
 	}
 	for name, chartVersions := range indexFile.Entries {
-		id, ok := applicationId[name]
+		app, ok := applicationByName[name]
 		if !ok {
 			//new app create AppStore
-			app := &sql.AppStore{
+			app = &sql.AppStore{
 				Name:        name,
+				ChartName:   name,
+				DisplayName: name,
 				ChartRepoId: repo.Id,
 				CreatedOn:   time.Now(),
 				UpdatedOn:   time.Now(),
@@ -288,34 +677,172 @@ func (impl *SyncServiceImpl) syncRepo(repo *sql.ChartRepo) error {
 				impl.logger.Errorw("error in saving app", "app", app, "err", err)
 				continue
 			}
-			applicationId[name] = app.Id
-			id = app.Id
+			applicationByName[name] = app
+		}
+		id := app.Id
+		versionFilter, err := util.NewVersionFilter(repo.VersionFilter, app.VersionFilter)
+		if err != nil {
+			impl.logger.Errorw("error in parsing version filter of chart", "repoName", repo.Name, "chartName", name, "err", err)
+			continue
 		}
 		//update entries if any  id, chartVersions
 		impl.logger.Infow("handling all versions of chart", "repoName", repo.Name, "chartName", name, "chartVersions", len(chartVersions))
-		err := impl.updateChartVersions(id, &chartVersions, repo.Url, repo.Username, repo.Password, repo.AllowInsecureConnection)
+		err = impl.updateChartVersions(id, &chartVersions, repo, chartRepoGetter, registryClientGetter, versionFilter)
 		if err != nil {
 			impl.logger.Errorw("error in updating chart versions", "err", err, "appId", id)
 			continue
 		}
+		err = impl.backfillChartVersionsMetadata(id)
+		if err != nil {
+			impl.logger.Errorw("error in backfilling metadata of chart versions", "err", err, "appId", id)
+			continue
+		}
 	}
 	return nil
 }
 
-func (impl *SyncServiceImpl) updateChartVersions(appId int, chartVersions *repo.ChartVersions, repoUrl string, username string, password string, allowInsecureConnection bool) error {
+// getRegistryClientGetter returns a RegistryClientGetter for oci:// chart urls listed in the index of a chart repo, a registry
+// client is created once per registry host.
+func (impl *SyncServiceImpl) getRegistryClientGetter(chartRepo *sql.ChartRepo, chartRepoGetter *util.ChartRepoGetter) RegistryClientGetter {
+	registryClients := make(map[string]*registry.Client)
+	return func(registryHost string) (*registry.Client, error) {
+		if client, ok := registryClients[registryHost]; ok {
+			return client, nil
+		}
+		client, err := impl.newRegistryClientForChartRepo(chartRepo, chartRepoGetter, registryHost)
+		if err != nil {
+			return nil, err
+		}
+		registryClients[registryHost] = client
+		return client, nil
+	}
+}
+
+// newRegistryClientForChartRepo creates a registry client for an OCI registry referenced from the index of a chart repo.
+// Basic auth credentials of the chart repo are used for its own host (or any host with PassCredentialsAll), otherwise
+// the credentials of the docker artifact store with the same registry host are used. Anonymous access is used when none match.
+// The registry is reached through the proxy or ssh tunnel of the chart repo like the chart repo itself.
+func (impl *SyncServiceImpl) newRegistryClientForChartRepo(chartRepo *sql.ChartRepo, chartRepoGetter *util.ChartRepoGetter, registryHost string) (*registry.Client, error) {
+	chartRepoUrl, err := url2.Parse(chartRepo.Url)
+	if err != nil {
+		return nil, err
+	}
+	if (chartRepo.PassCredentialsAll || chartRepoUrl.Host == registryHost) && util.GetChartRepoAuthMode(chartRepo) == sql.AUTH_MODE_USERNAME_PASSWORD &&
+		(len(chartRepo.Username) > 0 || len(chartRepo.Password) > 0) {
+		tlsConfig, err := registry2.NewTLSConfig("", "", "", chartRepo.AllowInsecureConnection)
+		if err != nil {
+			return nil, err
+		}
+		registryConfig := &registry3.Configuration{
+			RegistryUrl: registryHost,
+			Username:    chartRepo.Username,
+			Password:    chartRepo.Password,
+		}
+		client, err := registry2.NewRegistryClient(registryConfig, chartRepoGetter.NewHttpClient(tlsConfig))
+		if err != nil {
+			impl.logger.Errorw("error in creating registry client with chart repo credentials", "repo", chartRepo.Name, "registryHost", registryHost, "err", err)
+			return nil, err
+		}
+		return client, nil
+	}
+	stores, err := impl.providerService.GetOCIRegistries()
+	if err != nil {
+		impl.logger.Errorw("error in getting docker artifact stores", "err", err)
+		return nil, err
+	}
+	for _, store := range stores {
+		storeHost, _, _ := strings.Cut(TrimSchemeFromURL(store.RegistryURL), "/")
+		if storeHost != registryHost {
+			continue
+		}
+		var ociRegistryConfig *sql.OCIRegistryConfig
+		if chartPullConfigs := util.GetChartPullConfigs(store); len(chartPullConfigs) > 0 {
+			ociRegistryConfig = chartPullConfigs[0]
+		}
+		err = impl.secretResolver.ResolveDockerArtifactStore(store)
+		if err != nil {
+			return nil, err
+		}
+		registryConfig, err := registry2.NewToRegistryConfig(store, ociRegistryConfig)
+		if err != nil {
+			impl.logger.Errorw("error in getting registry config", "registryName", store.Id, "err", err)
+			return nil, err
+		}
+		err = impl.setRegistryCredential(registryConfig)
+		if err != nil {
+			impl.logger.Errorw("error in getting credential of registry", "registryName", store.Id, "err", err)
+			return nil, err
+		}
+		impl.warnPlainHttpConnection(store)
+		tlsConfig, err := registry2.GetTlsConfig(store)
+		if err != nil {
+			impl.logger.Errorw("error in getting tls config for registry", "registryName", store.Id, "err", err)
+			return nil, err
+		}
+		client, err := registry2.NewRegistryClient(registryConfig, chartRepoGetter.NewHttpClient(tlsConfig))
+		if err != nil {
+			impl.logger.Errorw("error in creating registry client", "registryName", store.Id, "err", err)
+			return nil, err
+		}
+		return client, nil
+	}
+	// the registry is not the one of the chart repo, so it is verified whether or not the chart repo allows insecure connections
+	tlsConfig, err := registry2.NewTLSConfig("", "", "", false)
+	if err != nil {
+		return nil, err
+	}
+	return registry2.NewRegistryClient(&registry3.Configuration{RegistryUrl: registryHost}, chartRepoGetter.NewHttpClient(tlsConfig))
+}
+
+// warnPlainHttpConnection logs a warning for stores synced over plain http, as their credentials and charts are sent
+// unencrypted
+func (impl *SyncServiceImpl) warnPlainHttpConnection(store *sql.DockerArtifactStore) {
+	if store.Connection != registry2.PLAIN_HTTP_CONNECTION {
+		return
+	}
+	impl.logger.Warnw("PLAIN HTTP CONNECTION: registry is accessed over plain http, credentials and charts are transferred unencrypted",
+		"registryName", store.Id, "registryUrl", store.RegistryURL, "hasCredentials", len(store.Username) > 0 || len(store.Password) > 0)
+}
+
+// setRegistryCredential replaces the configured credentials of the registry with the ones to access it with, which
+// are the cached authorization token for ECR registries
+func (impl *SyncServiceImpl) setRegistryCredential(registryConfig *registry3.Configuration) error {
+	if registryConfig.IsPublicRegistry {
+		return nil
+	}
+	credential, err := impl.credentialProvider.GetCredential(registryConfig)
+	if err != nil {
+		return err
+	}
+	registryConfig.Username = credential.Username
+	registryConfig.Password = credential.Password
+	return nil
+}
+
+func (impl *SyncServiceImpl) updateChartVersions(appId int, chartVersions *repo.ChartVersions, chartRepo *sql.ChartRepo, chartRepoGetter *util.ChartRepoGetter, registryClientGetter RegistryClientGetter, versionFilter *util.VersionFilter) error {
 	applicationVersions, err := impl.appStoreApplicationVersionRepository.FindVersionsByAppStoreId(appId)
 	if err != nil {
 		impl.logger.Errorw("error in getting application versions ", "err", err, "appId", appId)
 		return err
 	}
-	applicationVersionMaps := make(map[string]int)
-
+	applicationVersionMaps := make(map[string]*sql.AppStoreApplicationVersion)
+	versions := make([]string, 0, len(*chartVersions))
+	for _, chartVersion := range *chartVersions {
+		versions = append(versions, chartVersion.Version)
+	}
 	for _, applicationVersion := range applicationVersions {
-		applicationVersionMaps[applicationVersion.Version] = applicationVersion.Id
+		applicationVersionMaps[applicationVersion.Version] = applicationVersion
+		if !applicationVersion.Deprecated && !slices.Contains(versions, applicationVersion.Version) {
+			versions = append(versions, applicationVersion.Version)
+		}
+	}
+	err = impl.deprecateOutdatedChartVersions(appId, versions, applicationVersionMaps, versionFilter)
+	if err != nil {
+		return err
 	}
 	var appVersions []*sql.AppStoreApplicationVersion
 	var isAnyChartVersionFound bool
-	for _, chartVersion := range *chartVersions {
+	for _, chartVersion := range filterChartVersions(*chartVersions, versionFilter) {
 		if _, ok := applicationVersionMaps[chartVersion.Version]; ok {
 			//already present
 			impl.logger.Warnw("ignoring chart version as this already exists", "appStoreId", appId, "chartVersion", chartVersion.Version)
@@ -326,13 +853,13 @@ func (impl *SyncServiceImpl) updateChartVersions(appId int, chartVersions *repo.
 			impl.logger.Errorw("error in marshaling json", "err", err)
 			continue
 		}
-		rawValues, readme, valuesSchemaJson, notes, err := impl.helmRepoManager.ValuesJson(repoUrl, chartVersion, username, password, allowInsecureConnection)
+		chartData, err := impl.helmRepoManager.ValuesJson(chartRepo, chartVersion, chartRepoGetter, registryClientGetter)
 		if err != nil {
 			impl.logger.Errorw("error in getting values yaml", "err", err)
 			continue
 		}
 
-		jsonByte, err := yaml.YAMLToJSON([]byte(rawValues))
+		jsonByte, err := yaml.YAMLToJSON([]byte(chartData.RawValues))
 		if err != nil {
 			impl.logger.Errorw("error in getting values yaml", "err", err)
 			continue
@@ -357,23 +884,24 @@ func (impl *SyncServiceImpl) updateChartVersions(appId int, chartVersions *repo.
 			Digest:      chartVersion.Digest,
 			Icon:        chartVersion.Icon,
 			Name:        chartVersion.Name,
-			//Source:      chartVersion.Sources, //FIXME
-			Home:       chartVersion.Home,
-			ValuesYaml: string(jsonByte),
-			ChartYaml:  string(chartVersionJson),
-			AppStoreId: appId,
+			Home:        chartVersion.Home,
+			ValuesYaml:  string(jsonByte),
+			ChartYaml:   string(chartVersionJson),
+			AppStoreId:  appId,
 			AuditLog: sql.AuditLog{
 				CreatedOn: time.Now(),
 				UpdatedOn: time.Now(),
 				CreatedBy: 1,
 				UpdatedBy: 1,
 			},
-			RawValues:        rawValues,
-			Readme:           readme,
-			ValuesSchemaJson: valuesSchemaJson,
-			Notes:            notes,
+			RawValues:        chartData.RawValues,
+			Readme:           chartData.Readme,
+			ValuesSchemaJson: chartData.ValuesSchemaJson,
+			Notes:            chartData.Notes,
+			ChartArchiveUrl:  chartData.ChartArchiveUrl,
 			AppStore:         nil,
 		}
+		setStructuredMetadata(application, chartVersion.Metadata)
 		appVersions = append(appVersions, application)
 
 		// save 20 versions and reset the array (as memory would go increasing if save on one-go)
@@ -408,22 +936,30 @@ func (impl *SyncServiceImpl) updateChartVersions(appId int, chartVersions *repo.
 	return nil
 }
 
-func (impl *SyncServiceImpl) updateOCIRegistryChartVersions(client *registry.Client, appId int, chartVersions []string, ociRepo *sql.DockerArtifactStore, chartName string) error {
+func (impl *SyncServiceImpl) updateOCIRegistryChartVersions(ociClient *registry2.OCIClient, appId int, chartVersions []string, repository string, versionFilter *util.VersionFilter) error {
 
 	chartVersionsCount := len(chartVersions)
 
-	newChartVersions, err := impl.getNewChartVersions(appId, chartVersions)
+	newChartVersions, err := impl.getNewChartVersions(appId, chartVersions, versionFilter)
 	if err != nil {
 		impl.logger.Errorw("error in getting new chart versions", "appStoreId", appId, "err", err)
 		return err
 	}
+	versionByDigest, err := impl.getApplicationVersionsByDigest(appId)
+	if err != nil {
+		return err
+	}
+	fallbackCreated := getOCIChartFallbackCreated(time.Now(), newChartVersions)
 
 	var appVersions []*sql.AppStoreApplicationVersion
 	var isAnyChartVersionFound bool
 	for _, chartVersion := range newChartVersions {
 
-		chartData, err := impl.helmRepoManager.OCIRepoValuesJson(client, ociRepo.RegistryURL, chartName, chartVersion)
-		if err != nil {
+		chartData, err := impl.getOCIChartData(ociClient, repository, chartVersion, appId, versionByDigest)
+		if errors.Is(err, ErrNotHelmChart) {
+			impl.logger.Debugw("skipping tag which is not a helm chart", "repository", repository, "tag", chartVersion)
+			continue
+		} else if err != nil {
 			impl.logger.Errorw("error in getting values yaml", "err", err)
 			continue
 		}
@@ -432,7 +968,7 @@ func (impl *SyncServiceImpl) updateOCIRegistryChartVersions(client *registry.Cli
 			isAnyChartVersionFound = true
 		}
 
-		application, err := impl.parseAppStoreApplicationDbObj(chartVersion, chartData, appId)
+		application, err := impl.parseAppStoreApplicationDbObj(chartVersion, chartData, appId, fallbackCreated[chartVersion])
 		if err != nil {
 			impl.logger.Errorw("error in parsing app store application object", "appStoreId", appId, "chartVersion", chartVersion, "err", err)
 			return err
@@ -470,14 +1006,25 @@ func (impl *SyncServiceImpl) updateOCIRegistryChartVersions(client *registry.Cli
 	return nil
 }
 
-func (impl *SyncServiceImpl) getNewChartVersions(appId int, chartVersions []string) ([]string, error) {
-	applicationVersionMaps, err := impl.getApplicationVersionsMapping(appId)
+// getNewChartVersions returns the listed versions passing the version filter which are not stored yet. Keep latest
+// applies to the stored versions along with the listed ones, which are only the new tags on incremental listings, and
+// the stored versions which are no longer among the latest are deprecated.
+func (impl *SyncServiceImpl) getNewChartVersions(appId int, chartVersions []string, versionFilter *util.VersionFilter) ([]string, error) {
+	applicationVersions, err := impl.appStoreApplicationVersionRepository.FindVersionsByAppStoreId(appId)
 	if err != nil {
-		impl.logger.Errorw("error in getting application versions mapping")
+		impl.logger.Errorw("error in getting application versions ", "err", err, "appId", appId)
 		return nil, err
 	}
+	applicationVersionMaps := make(map[string]*sql.AppStoreApplicationVersion)
+	versions := slices.Clone(chartVersions)
+	for _, applicationVersion := range applicationVersions {
+		applicationVersionMaps[applicationVersion.Version] = applicationVersion
+		if !applicationVersion.Deprecated && !slices.Contains(chartVersions, applicationVersion.Version) {
+			versions = append(versions, applicationVersion.Version)
+		}
+	}
 	newChartVersions := make([]string, 0)
-	for _, chartVersion := range chartVersions {
+	for _, chartVersion := range versionFilter.Filter(versions) {
 		if _, ok := applicationVersionMaps[chartVersion]; ok {
 			//already present
 			impl.logger.Warnw("ignoring chart version as this already exists", "appStoreId", appId, "chartVersion", chartVersion)
@@ -485,23 +1032,214 @@ func (impl *SyncServiceImpl) getNewChartVersions(appId int, chartVersions []stri
 		}
 		newChartVersions = append(newChartVersions, chartVersion)
 	}
+	err = impl.deprecateOutdatedChartVersions(appId, versions, applicationVersionMaps, versionFilter)
+	if err != nil {
+		return nil, err
+	}
 	return newChartVersions, nil
 }
 
-func (impl *SyncServiceImpl) getApplicationVersionsMapping(appStoreId int) (map[string]int, error) {
+// deprecateOutdatedChartVersions deprecates the stored versions which are not among the latest versions to keep of the
+// versions, which are the listed versions along with the stored ones that are not deprecated yet
+func (impl *SyncServiceImpl) deprecateOutdatedChartVersions(appId int, versions []string, applicationVersionMaps map[string]*sql.AppStoreApplicationVersion, versionFilter *util.VersionFilter) error {
+	outdatedVersions := make([]*sql.AppStoreApplicationVersion, 0)
+	for _, version := range versionFilter.Outdated(versions) {
+		if applicationVersion, ok := applicationVersionMaps[version]; ok && !applicationVersion.Deprecated {
+			applicationVersion.Deprecated = true
+			applicationVersion.UpdatedOn = time.Now()
+			outdatedVersions = append(outdatedVersions, applicationVersion)
+		}
+	}
+	if len(outdatedVersions) == 0 {
+		return nil
+	}
+	impl.logger.Infow("deprecating chart versions which are not among the latest versions to keep", "appId", appId, "versions", len(outdatedVersions))
+	err := impl.appStoreApplicationVersionRepository.Update(outdatedVersions)
+	if err != nil {
+		impl.logger.Errorw("error in deprecating outdated chart versions", "appId", appId, "err", err)
+		return err
+	}
+	return nil
+}
+
+// getOCIChartData reads the metadata of a chart tag from its manifest and config. The chart layer is only downloaded when
+// no version of the chart has the same layer digest yet, like a chart pushed again under another tag.
+func (impl *SyncServiceImpl) getOCIChartData(ociClient *registry2.OCIClient, repository, tag string, appId int, versionByDigest map[string]string) (chartData ChartData, err error) {
+	defer func() {
+		if err == nil {
+			chartData.ChartArchiveUrl = ociClient.Reference(repository, tag)
+		}
+	}()
+	chartManifest, err := impl.helmRepoManager.FetchOCIChartManifest(ociClient, repository, tag)
+	if err != nil {
+		return ChartData{}, err
+	}
+	digest := chartManifest.ChartLayer.Digest.String()
+	if version, ok := versionByDigest[digest]; ok {
+		applicationVersion, err := impl.appStoreApplicationVersionRepository.FindOneByAppStoreIdAndVersion(appId, version)
+		if err == nil {
+			impl.logger.Debugw("reusing chart content of version with same digest", "repository", repository, "tag", tag, "version", version, "digest", digest)
+			return ChartData{
+				MetaData:         chartManifest.MetaData,
+				RawValues:        applicationVersion.RawValues,
+				Readme:           applicationVersion.Readme,
+				ValuesSchemaJson: applicationVersion.ValuesSchemaJson,
+				Notes:            applicationVersion.Notes,
+				Digest:           digest,
+				Created:          chartManifest.Created,
+			}, nil
+		}
+		impl.logger.Warnw("error in getting version with same digest, downloading chart layer", "appStoreId", appId, "version", version, "err", err)
+	}
+	return impl.helmRepoManager.LoadOCIChartContent(ociClient, repository, chartManifest)
+}
+
+// setStructuredMetadata sets the sources, keywords, kubeVersion constraint, type, maintainers and dependencies of the
+// version from its Chart.yaml
+func setStructuredMetadata(application *sql.AppStoreApplicationVersion, metadata *chart.Metadata) {
+	if metadata == nil {
+		return
+	}
+	application.Source = strings.Join(metadata.Sources, ",")
+	application.Keywords = strings.Join(metadata.Keywords, ",")
+	application.KubeVersion = metadata.KubeVersion
+	application.ChartType = metadata.Type
+	if len(application.ChartType) == 0 {
+		// helm treats charts without type as application charts
+		application.ChartType = "application"
+	}
+	application.Maintainers = make([]*sql.AppStoreApplicationVersionMaintainer, 0, len(metadata.Maintainers))
+	for _, maintainer := range metadata.Maintainers {
+		if maintainer == nil {
+			continue
+		}
+		application.Maintainers = append(application.Maintainers, &sql.AppStoreApplicationVersionMaintainer{
+			Name:  maintainer.Name,
+			Email: maintainer.Email,
+			Url:   maintainer.URL,
+		})
+	}
+	application.Dependencies = make([]*sql.AppStoreApplicationVersionDependency, 0, len(metadata.Dependencies))
+	for _, dependency := range metadata.Dependencies {
+		if dependency == nil {
+			continue
+		}
+		application.Dependencies = append(application.Dependencies, &sql.AppStoreApplicationVersionDependency{
+			Name:       dependency.Name,
+			Version:    dependency.Version,
+			Repository: dependency.Repository,
+			Condition:  dependency.Condition,
+			Tags:       strings.Join(dependency.Tags, ","),
+			Alias:      dependency.Alias,
+		})
+	}
+}
+
+// backfillChartVersionsMetadata sets the structured metadata of versions synced before it was recorded from their chart yaml
+func (impl *SyncServiceImpl) backfillChartVersionsMetadata(appId int) error {
+	applicationVersions, err := impl.appStoreApplicationVersionRepository.FindVersionsWithoutChartType(appId)
+	if err != nil {
+		impl.logger.Errorw("error in getting application versions ", "err", err, "appId", appId)
+		return err
+	}
+	backfilledVersions := make([]*sql.AppStoreApplicationVersion, 0)
+	for _, applicationVersion := range applicationVersions {
+		if len(applicationVersion.ChartType) > 0 {
+			continue
+		}
+		chartVersion := &repo.ChartVersion{}
+		// chart yaml of OCI charts synced earlier is only the tag until it is backfilled
+		if err := json.Unmarshal([]byte(applicationVersion.ChartYaml), chartVersion); err != nil || chartVersion.Metadata == nil {
+			continue
+		}
+		setStructuredMetadata(applicationVersion, chartVersion.Metadata)
+		applicationVersion.UpdatedOn = time.Now()
+		backfilledVersions = append(backfilledVersions, applicationVersion)
+	}
+	if len(backfilledVersions) == 0 {
+		return nil
+	}
+	impl.logger.Infow("backfilling metadata of chart versions", "appId", appId, "versions", len(backfilledVersions))
+	return impl.appStoreApplicationVersionRepository.UpdateMetadata(backfilledVersions)
+}
+
+// getOCIChartYaml returns the chart yaml of an OCI chart version in the shape of the index entries stored for chart repos
+func getOCIChartYaml(metadata *chart.Metadata, chartUrl, digest string, created time.Time) ([]byte, error) {
+	return json.Marshal(&repo.ChartVersion{
+		Metadata: metadata,
+		URLs:     []string{chartUrl},
+		Created:  created,
+		Digest:   digest,
+	})
+}
+
+// getApplicationVersionsByDigest returns the versions of the app by their chart digest
+func (impl *SyncServiceImpl) getApplicationVersionsByDigest(appStoreId int) (map[string]string, error) {
 	applicationVersions, err := impl.appStoreApplicationVersionRepository.FindVersionsByAppStoreId(appStoreId)
 	if err != nil {
 		impl.logger.Errorw("error in getting application versions ", "err", err, "appId", appStoreId)
 		return nil, err
 	}
-	applicationVersionMaps := make(map[string]int)
+	versionByDigest := make(map[string]string)
 	for _, applicationVersion := range applicationVersions {
-		applicationVersionMaps[applicationVersion.Version] = applicationVersion.Id
+		if len(applicationVersion.Digest) > 0 {
+			versionByDigest[applicationVersion.Digest] = applicationVersion.Version
+		}
+	}
+	return versionByDigest, nil
+}
+
+// filterChartVersions returns the index entries whose versions pass the version filter
+func filterChartVersions(chartVersions repo.ChartVersions, versionFilter *util.VersionFilter) repo.ChartVersions {
+	versions := make([]string, 0, len(chartVersions))
+	for _, chartVersion := range chartVersions {
+		versions = append(versions, chartVersion.Version)
+	}
+	filteredVersions := versionFilter.Filter(versions)
+	filteredChartVersions := make(repo.ChartVersions, 0, len(filteredVersions))
+	for _, chartVersion := range chartVersions {
+		if slices.Contains(filteredVersions, chartVersion.Version) {
+			filteredChartVersions = append(filteredChartVersions, chartVersion)
+		}
+	}
+	return filteredChartVersions
+}
+
+// getOCIChartFallbackCreated returns the creation time of chart versions whose registry does not tell when they were
+// created. It is the time of the sync which first saw the versions, which is stored along with the version and not
+// derived again. Versions seen by the same sync are a microsecond apart in the order of semver, so that the latest one
+// is still marked as latest, and versions which are not semver come first.
+func getOCIChartFallbackCreated(firstSynced time.Time, versions []string) map[string]time.Time {
+	semverVersions := make(map[string]*semver.Version)
+	for _, version := range versions {
+		// helm replaces + of the build metadata with _ in OCI tags
+		if semverVersion, err := semver.NewVersion(strings.ReplaceAll(version, "_", "+")); err == nil {
+			semverVersions[version] = semverVersion
+		}
+	}
+	sortedVersions := slices.Clone(versions)
+	slices.SortStableFunc(sortedVersions, func(a, b string) int {
+		semverA, okA := semverVersions[a]
+		semverB, okB := semverVersions[b]
+		switch {
+		case okA && okB:
+			return semverA.Compare(semverB)
+		case okA:
+			return 1
+		case okB:
+			return -1
+		}
+		return strings.Compare(a, b)
+	})
+	firstSynced = firstSynced.UTC().Truncate(time.Microsecond)
+	fallbackCreated := make(map[string]time.Time, len(sortedVersions))
+	for i, version := range sortedVersions {
+		fallbackCreated[version] = firstSynced.Add(time.Duration(i) * time.Microsecond)
 	}
-	return applicationVersionMaps, nil
+	return fallbackCreated
 }
 
-func (impl *SyncServiceImpl) parseAppStoreApplicationDbObj(chartVersion string, chartData ChartData, appId int) (*sql.AppStoreApplicationVersion, error) {
+func (impl *SyncServiceImpl) parseAppStoreApplicationDbObj(chartVersion string, chartData ChartData, appId int, fallbackCreated time.Time) (*sql.AppStoreApplicationVersion, error) {
 
 	jsonByte, err := yaml.YAMLToJSON([]byte(chartData.RawValues))
 	if err != nil {
@@ -509,15 +1247,21 @@ func (impl *SyncServiceImpl) parseAppStoreApplicationDbObj(chartVersion string,
 		return nil, err
 	}
 
-	chartVersionJson, err := json.Marshal(chartVersion)
+	now := time.Now()
+	created := chartData.Created
+	if created.IsZero() {
+		// Created field is used in marking chart latest
+		created = fallbackCreated
+	}
+	chartVersionJson, err := getOCIChartYaml(chartData.MetaData, chartData.ChartArchiveUrl, chartData.Digest, created)
 	if err != nil {
 		impl.logger.Errorw("error in marshaling json", "err", err)
 		return nil, err
 	}
-
 	application := &sql.AppStoreApplicationVersion{
 		Id:          0,
 		Version:     chartVersion,
+		Created:     created,
 		Description: chartData.MetaData.Description,
 		AppVersion:  chartData.MetaData.AppVersion,
 		Digest:      chartData.Digest,
@@ -529,8 +1273,8 @@ func (impl *SyncServiceImpl) parseAppStoreApplicationDbObj(chartVersion string,
 		ChartYaml:   string(chartVersionJson),
 		AppStoreId:  appId,
 		AuditLog: sql.AuditLog{
-			CreatedOn: time.Now(),
-			UpdatedOn: time.Now(),
+			CreatedOn: now,
+			UpdatedOn: now,
 			CreatedBy: 1,
 			UpdatedBy: 1,
 		},
@@ -538,18 +1282,25 @@ func (impl *SyncServiceImpl) parseAppStoreApplicationDbObj(chartVersion string,
 		Readme:           chartData.Readme,
 		ValuesSchemaJson: chartData.ValuesSchemaJson,
 		Notes:            chartData.Notes,
+		ChartArchiveUrl:  chartData.ChartArchiveUrl,
 		AppStore:         nil,
 	}
+	setStructuredMetadata(application, chartData.MetaData)
 	return application, nil
 }
 
-func (impl *SyncServiceImpl) updateOCIRegistryChartVersionsV2(client *registry.Client, appId int, chartVersions []string, ociRepo *sql.DockerArtifactStore, chartName string) error {
+func (impl *SyncServiceImpl) updateOCIRegistryChartVersionsV2(ociClient *registry2.OCIClient, appId int, chartVersions []string, repository string, versionFilter *util.VersionFilter) error {
 
-	newChartVersions, err := impl.getNewChartVersions(appId, chartVersions)
+	newChartVersions, err := impl.getNewChartVersions(appId, chartVersions, versionFilter)
 	if err != nil {
 		impl.logger.Errorw("error in getting new chart versions", "appStoreId", appId, "err", err)
 		return err
 	}
+	versionByDigest, err := impl.getApplicationVersionsByDigest(appId)
+	if err != nil {
+		return err
+	}
+	fallbackCreated := getOCIChartFallbackCreated(time.Now(), newChartVersions)
 
 	var isAnyChartVersionFound bool
 
@@ -564,15 +1315,18 @@ func (impl *SyncServiceImpl) updateOCIRegistryChartVersionsV2(client *registry.C
 		wg.Add(1)
 		workerPool <- struct{}{}
 
-		go func(client *registry.Client, registryURL, chartName string, chartVersion string) {
+		go func(ociClient *registry2.OCIClient, repository string, chartVersion string) {
 
 			defer func() {
 				wg.Done()
 				<-workerPool
 			}()
 
-			chartData, err := impl.helmRepoManager.OCIRepoValuesJson(client, ociRepo.RegistryURL, chartName, chartVersion)
-			if err != nil {
+			chartData, err := impl.getOCIChartData(ociClient, repository, chartVersion, appId, versionByDigest)
+			if errors.Is(err, ErrNotHelmChart) {
+				impl.logger.Debugw("skipping tag which is not a helm chart", "repository", repository, "tag", chartVersion)
+				return
+			} else if err != nil {
 				impl.logger.Errorw("error in getting values yaml", "err", err)
 				return
 			}
@@ -581,7 +1335,7 @@ func (impl *SyncServiceImpl) updateOCIRegistryChartVersionsV2(client *registry.C
 				isAnyChartVersionFound = true
 			}
 
-			application, err := impl.parseAppStoreApplicationDbObj(chartVersion, chartData, appId)
+			application, err := impl.parseAppStoreApplicationDbObj(chartVersion, chartData, appId, fallbackCreated[chartVersion])
 			if err != nil {
 				impl.logger.Errorw("error in parsing app store application object", "appStoreId", appId, "chartVersion", chartVersion, "err", err)
 				return
@@ -600,7 +1354,7 @@ func (impl *SyncServiceImpl) updateOCIRegistryChartVersionsV2(client *registry.C
 				appVersions = nil
 			}
 
-		}(client, ociRepo.RegistryURL, chartName, cv)
+		}(ociClient, repository, cv)
 
 	}
 
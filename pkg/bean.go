@@ -1,8 +1,49 @@
 package pkg
 
-import "helm.sh/helm/v3/pkg/chart"
+import (
+	"errors"
+	registry2 "github.com/devtron-labs/chart-sync/pkg/registry"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/registry"
+	"time"
+)
+
+// ErrNotHelmChart is returned for OCI artifacts which are not helm charts, like container images pushed to the same repository
+var ErrNotHelmChart = errors.New("artifact is not a helm chart")
 
 type ChartData struct {
 	MetaData                                           *chart.Metadata
 	RawValues, Readme, ValuesSchemaJson, Notes, Digest string
+	ChartArchiveUrl                                    string    // url the chart archive was downloaded from, the oci:// reference for OCI charts
+	Created                                            time.Time // creation time of OCI charts, zero when the registry does not tell
+}
+
+const (
+	CertificateTypeCA     = "ca"
+	CertificateTypeClient = "client"
+)
+
+// SyncReport is the outcome of a sync run which needs the attention of the operator
+type SyncReport struct {
+	ExpiringCertificates []*ExpiringCertificate `json:"expiringCertificates,omitempty"`
+}
+
+// ExpiringCertificate is a certificate of a chart provider which has expired or expires within the warning period
+type ExpiringCertificate struct {
+	DockerArtifactStoreId string    `json:"dockerArtifactStoreId"`
+	CertificateType       string    `json:"certificateType"` // ca or client
+	NotAfter              time.Time `json:"notAfter"`
+	IsExpired             bool      `json:"isExpired"`
+}
+
+// RegistryClientGetter returns a registry client for the given registry host
+type RegistryClientGetter func(registryHost string) (*registry.Client, error)
+
+// OCIChartManifest is the manifest of a chart pushed to an OCI registry along with the Chart.yaml metadata read from its config
+type OCIChartManifest struct {
+	Manifest   *registry2.Manifest
+	MetaData   *chart.Metadata
+	ChartLayer ocispec.Descriptor
+	Created    time.Time
 }
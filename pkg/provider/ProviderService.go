@@ -0,0 +1,326 @@
+package provider
+
+import (
+	"fmt"
+	"github.com/devtron-labs/chart-sync/internals"
+	logger2 "github.com/devtron-labs/chart-sync/internals/logger"
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"github.com/devtron-labs/chart-sync/pkg/secret"
+	"go.uber.org/zap"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ProviderService returns the chart providers to sync, which are the ones of the chart_repo and docker_artifact_store
+// tables, of the providers file, or both depending on PROVIDERS_FILE_MODE
+type ProviderService interface {
+	// GetChartProviders returns the chart repos and registries to sync, limited to CHART_PROVIDER_ID. The providers file
+	// is read again on every call except in upsert mode so that changes to it are picked up in daemon mode, an invalid
+	// file fails the call before any provider is returned.
+	GetChartProviders() ([]*sql.ChartRepo, []*sql.DockerArtifactStore, error)
+	// UpsertProviders writes the providers of the file into the tables in one transaction in upsert mode, and does
+	// nothing in the other modes. It is called once at startup, before the first sync.
+	UpsertProviders() error
+	// GetOCIRegistries returns all registries charts can be pulled from, without reading the providers file again
+	GetOCIRegistries() ([]*sql.DockerArtifactStore, error)
+	// IsSyncDue reports whether the interval of the schedule of the provider has passed since its last sync, and
+	// records the sync of the provider when it has. Providers without a schedule are always due.
+	IsSyncDue(providerKey string) bool
+}
+
+type ProviderServiceImpl struct {
+	logger                        *zap.SugaredLogger
+	configuration                 *internals.Configuration
+	mode                          ProvidersFileMode
+	chartRepoRepository           sql.ChartRepoRepository
+	dockerArtifactStoreRepository sql.DockerArtifactStoreRepository
+	providerRepository            sql.ProviderRepository
+	secretResolver                secret.SecretResolver
+	providersFile                 *ProvidersFile
+	lastSyncedOn                  map[string]time.Time
+	mutex                         sync.Mutex
+}
+
+// NewProviderServiceImpl fails on an invalid providers file, so that it is reported before any sync starts
+func NewProviderServiceImpl(logger *zap.SugaredLogger,
+	configuration *internals.Configuration,
+	chartRepoRepository sql.ChartRepoRepository,
+	dockerArtifactStoreRepository sql.DockerArtifactStoreRepository,
+	providerRepository sql.ProviderRepository,
+	secretResolver secret.SecretResolver,
+) (*ProviderServiceImpl, error) {
+	impl := &ProviderServiceImpl{
+		logger:                        logger.Named(logger2.ComponentSync),
+		configuration:                 configuration,
+		mode:                          ProvidersFileMode(configuration.ProvidersFileMode),
+		chartRepoRepository:           chartRepoRepository,
+		dockerArtifactStoreRepository: dockerArtifactStoreRepository,
+		providerRepository:            providerRepository,
+		secretResolver:                secretResolver,
+		lastSyncedOn:                  make(map[string]time.Time),
+	}
+	if len(configuration.ProvidersFile) == 0 {
+		return impl, nil
+	}
+	switch impl.mode {
+	case ProvidersFileModeMerge, ProvidersFileModeUpsert, ProvidersFileModeDirect:
+	default:
+		return nil, fmt.Errorf("invalid PROVIDERS_FILE_MODE %s, must be merge, upsert or direct", configuration.ProvidersFileMode)
+	}
+	providersFile, err := LoadProvidersFile(configuration.ProvidersFile, impl.mode, configuration.DaemonMode, configuration.ProvidersFileUpsertResolvedSecrets)
+	if err != nil {
+		impl.logger.Errorw("error in loading providers file", "file", configuration.ProvidersFile, "err", err)
+		return nil, err
+	}
+	impl.providersFile = providersFile
+	impl.logger.Infow("loaded providers file", "file", configuration.ProvidersFile, "mode", impl.mode,
+		"chartRepos", len(providersFile.ChartRepos), "registries", len(providersFile.Registries))
+	return impl, nil
+}
+
+// ChartRepoKey returns the key of a chart repo for IsSyncDue
+func ChartRepoKey(chartRepo *sql.ChartRepo) string {
+	return "chart-repo/" + chartRepo.Name
+}
+
+// OCIRegistryKey returns the key of a registry for IsSyncDue
+func OCIRegistryKey(store *sql.DockerArtifactStore) string {
+	return "registry/" + store.Id
+}
+
+func (impl *ProviderServiceImpl) GetChartProviders() ([]*sql.ChartRepo, []*sql.DockerArtifactStore, error) {
+	// the providers of the file are in the tables in upsert mode
+	if len(impl.configuration.ProvidersFile) == 0 || impl.mode == ProvidersFileModeUpsert {
+		return impl.getDbChartProviders()
+	}
+	providersFile, err := LoadProvidersFile(impl.configuration.ProvidersFile, impl.mode, impl.configuration.DaemonMode, impl.configuration.ProvidersFileUpsertResolvedSecrets)
+	if err != nil {
+		impl.logger.Errorw("error in loading providers file", "file", impl.configuration.ProvidersFile, "err", err)
+		return nil, nil, err
+	}
+	impl.mutex.Lock()
+	impl.providersFile = providersFile
+	impl.mutex.Unlock()
+	repos, err := impl.getChartRepos(providersFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	ociRegistries, err := impl.getOCIRegistries(providersFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return impl.filterChartProviders(repos, ociRegistries)
+}
+
+func (impl *ProviderServiceImpl) GetOCIRegistries() ([]*sql.DockerArtifactStore, error) {
+	impl.mutex.Lock()
+	providersFile := impl.providersFile
+	impl.mutex.Unlock()
+	if providersFile == nil || impl.mode == ProvidersFileModeUpsert {
+		return impl.dockerArtifactStoreRepository.FindAllChartProviders()
+	}
+	return impl.getOCIRegistries(providersFile)
+}
+
+func (impl *ProviderServiceImpl) IsSyncDue(providerKey string) bool {
+	impl.mutex.Lock()
+	defer impl.mutex.Unlock()
+	interval := impl.getScheduleInterval(providerKey)
+	if lastSyncedOn, ok := impl.lastSyncedOn[providerKey]; ok && time.Since(lastSyncedOn) < interval {
+		return false
+	}
+	impl.lastSyncedOn[providerKey] = time.Now()
+	return true
+}
+
+func (impl *ProviderServiceImpl) getScheduleInterval(providerKey string) time.Duration {
+	if impl.providersFile == nil {
+		return 0
+	}
+	for _, chartRepo := range impl.providersFile.ChartRepos {
+		if ChartRepoKey(&sql.ChartRepo{Name: chartRepo.Name}) == providerKey {
+			return chartRepo.Schedule.getInterval()
+		}
+	}
+	for _, registryProvider := range impl.providersFile.Registries {
+		if OCIRegistryKey(&sql.DockerArtifactStore{Id: registryProvider.Id}) == providerKey {
+			return registryProvider.Schedule.getInterval()
+		}
+	}
+	return 0
+}
+
+// getDbChartProviders returns the providers of the tables, limited to CHART_PROVIDER_ID
+func (impl *ProviderServiceImpl) getDbChartProviders() ([]*sql.ChartRepo, []*sql.DockerArtifactStore, error) {
+	var (
+		err           error
+		repos         []*sql.ChartRepo
+		repo          *sql.ChartRepo
+		chartRepoId   int
+		ociRegistries []*sql.DockerArtifactStore
+		ociRegistry   *sql.DockerArtifactStore
+	)
+	if impl.configuration.ChartProviderId == "*" {
+		ociRegistries, err = impl.dockerArtifactStoreRepository.FindAllChartProviders()
+		if err != nil {
+			impl.logger.Errorw("err in getting OCI Registries list", "err", err)
+		}
+		repos, err = impl.chartRepoRepository.GetAll()
+		if err != nil {
+			impl.logger.Errorw("err in getting repo list", "err", err)
+		}
+	} else {
+		if impl.configuration.IsOCIRegistry {
+			ociRegistry, err = impl.dockerArtifactStoreRepository.FindOne(impl.configuration.ChartProviderId)
+			if err != nil {
+				impl.logger.Errorw("err in getting OCI Registries list", "err", err)
+				return nil, nil, err
+			}
+			ociRegistries = []*sql.DockerArtifactStore{ociRegistry}
+		} else {
+			chartRepoId, err = strconv.Atoi(impl.configuration.ChartProviderId)
+			if err != nil {
+				impl.logger.Errorw("err in parsing ChartProviderId", "err", err)
+				return nil, nil, err
+			}
+			repo, err = impl.chartRepoRepository.FindById(chartRepoId)
+			if err != nil {
+				impl.logger.Errorw("err in getting repo list", "err", err)
+				return nil, nil, err
+			}
+			repos = []*sql.ChartRepo{repo}
+		}
+	}
+	return repos, ociRegistries, nil
+}
+
+// getChartRepos returns the chart repos of the file, along with the ones of the table in merge mode
+func (impl *ProviderServiceImpl) getChartRepos(providersFile *ProvidersFile) ([]*sql.ChartRepo, error) {
+	var repos []*sql.ChartRepo
+	if impl.mode == ProvidersFileModeMerge {
+		var err error
+		repos, err = impl.chartRepoRepository.GetAll()
+		if err != nil {
+			impl.logger.Errorw("err in getting repo list", "err", err)
+			return nil, err
+		}
+	}
+	for _, chartRepoProvider := range providersFile.ChartRepos {
+		chartRepo := chartRepoProvider.toChartRepo()
+		index := -1
+		for i, repo := range repos {
+			if repo.Name == chartRepo.Name {
+				index = i
+				break
+			}
+		}
+		if index >= 0 {
+			if chartRepo.Id == 0 {
+				chartRepo.Id = repos[index].Id
+			}
+			repos[index] = chartRepo
+			continue
+		}
+		if chartRepo.Id == 0 {
+			return nil, fmt.Errorf("chart repo %s of providers file is not in chart_repo, its id has to be set", chartRepo.Name)
+		}
+		repos = append(repos, chartRepo)
+	}
+	return repos, nil
+}
+
+// getOCIRegistries returns the registries of the file, along with the ones of the table in merge mode
+func (impl *ProviderServiceImpl) getOCIRegistries(providersFile *ProvidersFile) ([]*sql.DockerArtifactStore, error) {
+	var ociRegistries []*sql.DockerArtifactStore
+	if impl.mode == ProvidersFileModeMerge {
+		var err error
+		ociRegistries, err = impl.dockerArtifactStoreRepository.FindAllChartProviders()
+		if err != nil {
+			impl.logger.Errorw("err in getting OCI Registries list", "err", err)
+			return nil, err
+		}
+	}
+	for _, registryProvider := range providersFile.Registries {
+		store := registryProvider.toDockerArtifactStore()
+		index := -1
+		for i, ociRegistry := range ociRegistries {
+			if ociRegistry.Id == store.Id {
+				index = i
+				break
+			}
+		}
+		if index >= 0 {
+			ociRegistries[index] = store
+		} else {
+			ociRegistries = append(ociRegistries, store)
+		}
+	}
+	return ociRegistries, nil
+}
+
+// filterChartProviders limits the providers to CHART_PROVIDER_ID
+func (impl *ProviderServiceImpl) filterChartProviders(repos []*sql.ChartRepo, ociRegistries []*sql.DockerArtifactStore) ([]*sql.ChartRepo, []*sql.DockerArtifactStore, error) {
+	if impl.configuration.ChartProviderId == "*" {
+		return repos, ociRegistries, nil
+	}
+	if impl.configuration.IsOCIRegistry {
+		for _, ociRegistry := range ociRegistries {
+			if ociRegistry.Id == impl.configuration.ChartProviderId {
+				return nil, []*sql.DockerArtifactStore{ociRegistry}, nil
+			}
+		}
+		return nil, nil, fmt.Errorf("registry %s not found", impl.configuration.ChartProviderId)
+	}
+	chartRepoId, err := strconv.Atoi(impl.configuration.ChartProviderId)
+	if err != nil {
+		impl.logger.Errorw("err in parsing ChartProviderId", "err", err)
+		return nil, nil, err
+	}
+	for _, repo := range repos {
+		if repo.Id == chartRepoId {
+			return []*sql.ChartRepo{repo}, nil, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("chart repo %d not found", chartRepoId)
+}
+
+// UpsertProviders only resolves secret references with PROVIDERS_FILE_UPSERT_RESOLVED_SECRETS, the providers file is
+// rejected when loaded otherwise
+func (impl *ProviderServiceImpl) UpsertProviders() error {
+	if impl.providersFile == nil || impl.mode != ProvidersFileModeUpsert {
+		return nil
+	}
+	chartRepos := make([]*sql.ChartRepo, 0, len(impl.providersFile.ChartRepos))
+	for _, chartRepoProvider := range impl.providersFile.ChartRepos {
+		chartRepos = append(chartRepos, chartRepoProvider.toChartRepo())
+	}
+	stores := make([]*sql.DockerArtifactStore, 0, len(impl.providersFile.Registries))
+	for _, registryProvider := range impl.providersFile.Registries {
+		stores = append(stores, registryProvider.toDockerArtifactStore())
+	}
+	if impl.configuration.ProvidersFileUpsertResolvedSecrets {
+		impl.logger.Warnw("writing resolved secrets of the providers file into the tables in plaintext as PROVIDERS_FILE_UPSERT_RESOLVED_SECRETS is set",
+			"file", impl.configuration.ProvidersFile)
+		// the orchestrator reads the same rows and can not resolve secret references
+		for _, chartRepo := range chartRepos {
+			err := impl.secretResolver.ResolveChartRepo(chartRepo)
+			if err != nil {
+				return err
+			}
+		}
+		for _, store := range stores {
+			err := impl.secretResolver.ResolveDockerArtifactStore(store)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err := impl.providerRepository.UpsertProviders(chartRepos, stores)
+	if err != nil {
+		impl.logger.Errorw("error in upserting providers of providers file", "file", impl.configuration.ProvidersFile, "err", err)
+		return err
+	}
+	impl.logger.Infow("upserted providers of providers file", "chartRepos", len(chartRepos), "registries", len(stores))
+	return nil
+}
@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/devtron-labs/chart-sync/internals"
+	"github.com/devtron-labs/chart-sync/internals/filestore"
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"github.com/devtron-labs/chart-sync/pkg/secret"
+	"go.uber.org/zap"
+)
+
+// testProviderTables are the rows of the providers file of the file store
+type testProviderTables struct {
+	ChartRepos              []*sql.ChartRepo              `json:"chartRepos"`
+	DockerArtifactStores    []*sql.DockerArtifactStore    `json:"dockerArtifactStores"`
+	OCIRegistryConfigs      []*sql.OCIRegistryConfig      `json:"ociRegistryConfigs"`
+	RemoteConnectionConfigs []*sql.RemoteConnectionConfig `json:"remoteConnectionConfigs"`
+}
+
+const testUpsertProvidersFile = `chartRepos:
+  - name: existing
+    url: https://charts.example.com
+    username: user
+    password: env://CHART_SYNC_TEST_PASSWORD
+    mirrorUrls: [https://mirror.example.com, https://mirror2.example.com]
+    versionFilter:
+      keepLatest: 5
+  - name: new
+    url: https://new.example.com
+registries:
+  - id: harbor
+    registryUrl: harbor.example.com
+    username: robot
+    password: registry-password
+    charts:
+      - repositories: [library/*, "!library/internal-*"]
+        versionFilter:
+          keepLatest: 3
+  - id: ecr
+    registryUrl: 123456789012.dkr.ecr.eu-west-1.amazonaws.com
+    registryType: ecr
+    awsRegion: eu-west-1
+    charts:
+      - repositoryDiscovery: true
+        repositoryDiscoveryPrefix: charts/
+`
+
+func newTestUpsertProviderService(t *testing.T, dir string, providersFile string, isUpsertingResolvedSecrets bool) (*ProviderServiceImpl, error) {
+	t.Helper()
+	providersFilePath := filepath.Join(t.TempDir(), "providers.yaml")
+	if err := os.WriteFile(providersFilePath, []byte(providersFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+	logger := zap.NewNop().Sugar()
+	configuration := &internals.Configuration{
+		ProvidersFile:                      providersFilePath,
+		ProvidersFileMode:                  string(ProvidersFileModeUpsert),
+		ProvidersFileUpsertResolvedSecrets: isUpsertingResolvedSecrets,
+		SecretReferenceEnvPrefix:           "CHART_SYNC_TEST_",
+		ChartProviderId:                    "*",
+	}
+	fileStore, err := filestore.NewFileStore(logger, dir)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	secretResolver := secret.NewSecretResolverImpl(logger, secret.NewEnvelopeSecretSource(logger, configuration), configuration)
+	return NewProviderServiceImpl(logger, configuration,
+		filestore.NewChartRepoRepositoryImpl(fileStore),
+		filestore.NewDockerArtifactStoreRepositoryImpl(fileStore),
+		filestore.NewProviderRepositoryImpl(fileStore),
+		secretResolver)
+}
+
+func TestProviderService_UpsertProviders(t *testing.T) {
+	t.Setenv("CHART_SYNC_TEST_PASSWORD", "password")
+	createdOn := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	dir := t.TempDir()
+	storedTables, _ := json.Marshal(&testProviderTables{
+		ChartRepos: []*sql.ChartRepo{
+			{Id: 7, Name: "existing", Url: "https://old.example.com", Active: true, External: true, Default: true, RemoteConnectionConfigId: 3,
+				AuditLog: sql.AuditLog{CreatedOn: createdOn, CreatedBy: 2}},
+			{Id: 8, Name: "deleted", Url: "https://deleted.example.com", Deleted: true},
+		},
+		DockerArtifactStores: []*sql.DockerArtifactStore{
+			{Id: "harbor", RegistryURL: "old.example.com", Active: true, IsDefault: true, RemoteConnectionConfigId: 3,
+				AuditLog: sql.AuditLog{CreatedOn: createdOn, CreatedBy: 2}},
+		},
+		OCIRegistryConfigs: []*sql.OCIRegistryConfig{
+			{Id: 11, DockerArtifactStoreId: "harbor", RepositoryType: sql.OCI_REGISRTY_REPO_TYPE_CHART, RepositoryList: "old/*",
+				AuditLog: sql.AuditLog{CreatedOn: createdOn, CreatedBy: 2}},
+			{Id: 12, DockerArtifactStoreId: "harbor", RepositoryType: sql.OCI_REGISRTY_REPO_TYPE_CHART, RepositoryList: "other/*"},
+			{Id: 13, DockerArtifactStoreId: "harbor", RepositoryType: "CONTAINER", RepositoryList: "images/*"},
+		},
+		RemoteConnectionConfigs: []*sql.RemoteConnectionConfig{{Id: 3, ConnectionMethod: "PROXY", ProxyUrl: "http://proxy.example.com:3128"}},
+	})
+	if err := os.WriteFile(filepath.Join(dir, "providers.json"), storedTables, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newTestUpsertProviderService(t, dir, testUpsertProvidersFile, false); err == nil {
+		t.Fatal("NewProviderServiceImpl() expected error for a secret reference without PROVIDERS_FILE_UPSERT_RESOLVED_SECRETS")
+	}
+	providerService, err := newTestUpsertProviderService(t, dir, testUpsertProvidersFile, true)
+	if err != nil {
+		t.Fatalf("NewProviderServiceImpl() error = %v", err)
+	}
+	if err = providerService.UpsertProviders(); err != nil {
+		t.Fatalf("UpsertProviders() error = %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "providers.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := &testProviderTables{}
+	if err = json.Unmarshal(content, tables); err != nil {
+		t.Fatal(err)
+	}
+	chartRepos := make(map[string]*sql.ChartRepo)
+	for _, chartRepo := range tables.ChartRepos {
+		chartRepos[chartRepo.Name] = chartRepo
+	}
+	existing := chartRepos["existing"]
+	if existing == nil || existing.Id != 7 || !existing.Default || existing.RemoteConnectionConfigId != 3 || !existing.CreatedOn.Equal(createdOn) || existing.CreatedBy != 2 {
+		t.Errorf("existing chart repo = %+v, want the id, default flag, remote connection and creation audit kept", existing)
+	} else {
+		if existing.Url != "https://charts.example.com" || existing.Username != "user" || existing.MirrorUrls != "https://mirror.example.com,https://mirror2.example.com" ||
+			existing.VersionFilter != `{"keepLatest":5}` || !existing.Active || !existing.External || existing.UpdatedBy != systemUserId {
+			t.Errorf("existing chart repo = %+v, want the columns of the providers file", existing)
+		}
+		if existing.Password != "password" {
+			t.Errorf("existing chart repo password = %q, want the resolved secret", existing.Password)
+		}
+	}
+	if created := chartRepos["new"]; created == nil || created.Id != 9 || created.Default || created.CreatedBy != systemUserId {
+		t.Errorf("new chart repo = %+v, want it inserted with the next id", created)
+	}
+	if len(tables.ChartRepos) != 3 {
+		t.Errorf("chart repos = %d, want the deleted one left as it is", len(tables.ChartRepos))
+	}
+
+	stores := make(map[string]*sql.DockerArtifactStore)
+	for _, store := range tables.DockerArtifactStores {
+		stores[store.Id] = store
+	}
+	harbor := stores["harbor"]
+	if harbor == nil || harbor.RegistryURL != "harbor.example.com" || harbor.Password != "registry-password" || harbor.RegistryType != sql.REGISTRYTYPE_OTHER ||
+		harbor.Connection != secureConnection || harbor.PluginId != dockerRegistryPluginId || !harbor.IsDefault || harbor.RemoteConnectionConfigId != 3 ||
+		!harbor.CreatedOn.Equal(createdOn) {
+		t.Errorf("harbor = %+v, want the columns of the providers file along with the default flag, remote connection and creation audit kept", harbor)
+	}
+	if ecr := stores["ecr"]; ecr == nil || ecr.RegistryType != sql.REGISTRYTYPE_ECR || ecr.AWSRegion != "eu-west-1" || !ecr.Active || !ecr.IsOCICompliantRegistry {
+		t.Errorf("ecr = %+v, want it inserted", ecr)
+	}
+
+	ociRegistryConfigs := make(map[int]*sql.OCIRegistryConfig)
+	for _, ociRegistryConfig := range tables.OCIRegistryConfigs {
+		ociRegistryConfigs[ociRegistryConfig.Id] = ociRegistryConfig
+	}
+	if config := ociRegistryConfigs[11]; config == nil || config.RepositoryList != "library/*,!library/internal-*" || config.VersionFilter != `{"keepLatest":3}` ||
+		!config.IsChartPullActive || config.RepositoryAction != sql.STORAGE_ACTION_TYPE_PULL || config.Deleted || !config.CreatedOn.Equal(createdOn) {
+		t.Errorf("chart pull config 11 = %+v, want it updated from the first chart config", config)
+	}
+	if config := ociRegistryConfigs[12]; config == nil || !config.Deleted {
+		t.Errorf("chart pull config 12 = %+v, want it deleted as the registry has one chart config", config)
+	}
+	if config := ociRegistryConfigs[13]; config == nil || config.Deleted || config.RepositoryList != "images/*" {
+		t.Errorf("container config 13 = %+v, want it left as it is", config)
+	}
+	if config := ociRegistryConfigs[14]; config == nil || config.DockerArtifactStoreId != "ecr" || !config.IsRepositoryDiscoveryEnabled || config.RepositoryDiscoveryPrefix != "charts/" {
+		t.Errorf("chart pull config 14 = %+v, want it inserted for ecr", config)
+	}
+}
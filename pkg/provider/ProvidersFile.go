@@ -0,0 +1,433 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	registry2 "github.com/devtron-labs/chart-sync/pkg/registry"
+	"github.com/devtron-labs/chart-sync/pkg/secret"
+	"github.com/devtron-labs/chart-sync/util"
+	"github.com/devtron-labs/common-lib/helmLib/registry"
+	"github.com/ghodss/yaml"
+	url2 "net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ProvidersFileMode is how the providers of the providers file are combined with the ones of the chart_repo and
+// docker_artifact_store tables
+type ProvidersFileMode string
+
+const (
+	// ProvidersFileModeMerge syncs the providers of the tables and of the file, a provider of the file replacing the one
+	// of the tables with the same chart repo name or registry id. The tables are not changed.
+	ProvidersFileModeMerge ProvidersFileMode = "merge"
+	// ProvidersFileModeUpsert writes the providers of the file into the tables once at startup and syncs the tables.
+	// Changes to the file are picked up on the next start. As the orchestrator reads the same rows and can not resolve
+	// secret references, they are rejected unless PROVIDERS_FILE_UPSERT_RESOLVED_SECRETS allows writing their resolved
+	// secrets into the tables.
+	ProvidersFileModeUpsert ProvidersFileMode = "upsert"
+	// ProvidersFileModeDirect only syncs the providers of the file, the tables are not read
+	ProvidersFileModeDirect ProvidersFileMode = "direct"
+)
+
+const (
+	// connection of registries which are reached over TLS verified against the system roots, the default
+	secureConnection = "secure"
+	// plugin of the docker artifact stores the orchestrator creates
+	dockerRegistryPluginId = "cd.docker.registry"
+	// user the rows upserted from the providers file are created and updated by
+	systemUserId = 1
+)
+
+// ProvidersFile declares chart providers, for running chart-sync standalone or managing its providers with GitOps:
+//
+//	chartRepos:
+//	  - name: bitnami
+//	    url: https://charts.bitnami.com/bitnami
+//	    versionFilter:
+//	      keepLatest: 10
+//	    schedule:
+//	      intervalInMinutes: 360
+//	registries:
+//	  - id: harbor
+//	    registryUrl: harbor.example.com
+//	    registryType: other
+//	    username: robot$chart-sync
+//	    password: env://CHART_SYNC_HARBOR_PASSWORD
+//	    charts:
+//	      - repositories: [library/*]
+//
+// Credentials are plaintext or secret references like env://, file://, k8s:// and enc://, which are resolved when the
+// provider is synced. File, env and k8s references only resolve when allowed, like CHART_SYNC_ environment variables
+// with SECRET_REFERENCE_ENV_PREFIX=CHART_SYNC_. In upsert mode secret references are rejected by default, see
+// ProvidersFileModeUpsert.
+type ProvidersFile struct {
+	ChartRepos []*ChartRepoProvider `json:"chartRepos,omitempty"`
+	Registries []*RegistryProvider  `json:"registries,omitempty"`
+}
+
+type ChartRepoProvider struct {
+	// id of the chart repo the app stores of its charts are linked to. It is taken from the chart repo with the same name
+	// in merge and upsert mode, and is required in direct mode and for repos only in the file in merge mode.
+	Id                      int                       `json:"id,omitempty"`
+	Name                    string                    `json:"name"`
+	Url                     string                    `json:"url"`
+	AuthMode                sql.AuthMode              `json:"authMode,omitempty"` // derived from the credentials when empty
+	Username                string                    `json:"username,omitempty"`
+	Password                string                    `json:"password,omitempty"`
+	AccessToken             string                    `json:"accessToken,omitempty"`
+	CustomHeaders           map[string]string         `json:"customHeaders,omitempty"`
+	AllowInsecureConnection bool                      `json:"allowInsecureConnection,omitempty"`
+	PassCredentialsAll      bool                      `json:"passCredentialsAll,omitempty"`
+	MirrorUrls              []string                  `json:"mirrorUrls,omitempty"`
+	VersionFilter           *util.VersionFilterConfig `json:"versionFilter,omitempty"`
+	Schedule                *Schedule                 `json:"schedule,omitempty"`
+}
+
+type RegistryProvider struct {
+	Id                 string                 `json:"id"`
+	RegistryUrl        string                 `json:"registryUrl"`
+	RegistryType       sql.RegistryType       `json:"registryType,omitempty"` // other when empty
+	Username           string                 `json:"username,omitempty"`
+	Password           string                 `json:"password,omitempty"`
+	AwsAccessKeyId     string                 `json:"awsAccessKeyId,omitempty"`
+	AwsSecretAccessKey string                 `json:"awsSecretAccessKey,omitempty"`
+	AwsRegion          string                 `json:"awsRegion,omitempty"`
+	Connection         string                 `json:"connection,omitempty"` // secure, insecure, secure-with-cert or plain-http
+	Cert               string                 `json:"cert,omitempty"`
+	ClientCert         string                 `json:"clientCert,omitempty"`
+	ClientKey          string                 `json:"clientKey,omitempty"`
+	Charts             []*RegistryChartConfig `json:"charts"`
+	Schedule           *Schedule              `json:"schedule,omitempty"`
+}
+
+// RegistryChartConfig selects the repositories of a registry to sync charts from, like an oci_registry_config row
+type RegistryChartConfig struct {
+	Repositories              []string                  `json:"repositories,omitempty"` // repositories and patterns like library/* and !library/internal-*
+	IsPublic                  bool                      `json:"isPublic,omitempty"`
+	RepositoryDiscovery       bool                      `json:"repositoryDiscovery,omitempty"`
+	RepositoryDiscoveryPrefix string                    `json:"repositoryDiscoveryPrefix,omitempty"`
+	VersionFilter             *util.VersionFilterConfig `json:"versionFilter,omitempty"`
+}
+
+// Schedule limits how often a provider is synced in daemon mode, it is synced on every sync when not set. It is rejected
+// outside daemon mode, as the last syncs of providers are only kept in memory and would not limit runs started by a cron job.
+type Schedule struct {
+	IntervalInMinutes int `json:"intervalInMinutes"`
+}
+
+func (schedule *Schedule) getInterval() time.Duration {
+	if schedule == nil {
+		return 0
+	}
+	return time.Duration(schedule.IntervalInMinutes) * time.Minute
+}
+
+// LoadProvidersFile reads and validates the providers file, unknown fields are rejected so that misspelt settings are
+// not silently ignored
+func LoadProvidersFile(path string, mode ProvidersFileMode, isDaemonMode, isUpsertingResolvedSecrets bool) (*ProvidersFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read providers file %s: %w", path, err)
+	}
+	jsonContent, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid providers file %s: %w", path, err)
+	}
+	providersFile := &ProvidersFile{}
+	decoder := json.NewDecoder(bytes.NewReader(jsonContent))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(providersFile); err != nil {
+		return nil, fmt.Errorf("invalid providers file %s: %w", path, err)
+	}
+	if err = providersFile.Validate(mode, isDaemonMode, isUpsertingResolvedSecrets); err != nil {
+		return nil, fmt.Errorf("invalid providers file %s: %w", path, err)
+	}
+	return providersFile, nil
+}
+
+// Validate returns all the errors of the providers file joined, so that they can be fixed at once. Secret references
+// are rejected in upsert mode unless resolved secrets are upserted.
+func (impl *ProvidersFile) Validate(mode ProvidersFileMode, isDaemonMode, isUpsertingResolvedSecrets bool) error {
+	isSecretReferenceAllowed := mode != ProvidersFileModeUpsert || isUpsertingResolvedSecrets
+	var errs []error
+	chartRepoNames := make(map[string]bool)
+	chartRepoIds := make(map[int]bool)
+	for i, chartRepo := range impl.ChartRepos {
+		if chartRepo == nil {
+			errs = append(errs, fmt.Errorf("chartRepos[%d]: empty chart repo", i))
+			continue
+		}
+		for _, err := range chartRepo.validate(mode, isDaemonMode, isSecretReferenceAllowed) {
+			errs = append(errs, fmt.Errorf("chartRepos[%d] %s: %w", i, chartRepo.Name, err))
+		}
+		if chartRepoNames[chartRepo.Name] {
+			errs = append(errs, fmt.Errorf("chartRepos[%d] %s: duplicate name", i, chartRepo.Name))
+		}
+		chartRepoNames[chartRepo.Name] = true
+		if chartRepo.Id > 0 && chartRepoIds[chartRepo.Id] {
+			errs = append(errs, fmt.Errorf("chartRepos[%d] %s: duplicate id %d", i, chartRepo.Name, chartRepo.Id))
+		}
+		chartRepoIds[chartRepo.Id] = true
+	}
+	registryIds := make(map[string]bool)
+	for i, registryProvider := range impl.Registries {
+		if registryProvider == nil {
+			errs = append(errs, fmt.Errorf("registries[%d]: empty registry", i))
+			continue
+		}
+		for _, err := range registryProvider.validate(mode, isDaemonMode, isSecretReferenceAllowed) {
+			errs = append(errs, fmt.Errorf("registries[%d] %s: %w", i, registryProvider.Id, err))
+		}
+		if registryIds[registryProvider.Id] {
+			errs = append(errs, fmt.Errorf("registries[%d] %s: duplicate id", i, registryProvider.Id))
+		}
+		registryIds[registryProvider.Id] = true
+	}
+	return errors.Join(errs...)
+}
+
+func (impl *ChartRepoProvider) validate(mode ProvidersFileMode, isDaemonMode, isSecretReferenceAllowed bool) []error {
+	var errs []error
+	if len(strings.TrimSpace(impl.Name)) == 0 {
+		errs = append(errs, errors.New("name is required"))
+	}
+	if impl.Id < 0 {
+		errs = append(errs, errors.New("id must not be negative"))
+	} else if impl.Id == 0 && mode == ProvidersFileModeDirect {
+		errs = append(errs, errors.New("id is required in direct mode"))
+	}
+	if err := validateHttpUrl(impl.Url); err != nil {
+		errs = append(errs, fmt.Errorf("url: %w", err))
+	}
+	for _, mirrorUrl := range impl.MirrorUrls {
+		if err := validateHttpUrl(mirrorUrl); err != nil {
+			errs = append(errs, fmt.Errorf("mirrorUrls: %w", err))
+		}
+	}
+	switch impl.AuthMode {
+	case "", sql.AUTH_MODE_ANONYMOUS:
+	case sql.AUTH_MODE_USERNAME_PASSWORD:
+		if len(impl.Username) == 0 || len(impl.Password) == 0 {
+			errs = append(errs, fmt.Errorf("username and password are required with auth mode %s", impl.AuthMode))
+		}
+	case sql.AUTH_MODE_ACCESS_TOKEN:
+		if len(impl.AccessToken) == 0 {
+			errs = append(errs, fmt.Errorf("accessToken is required with auth mode %s", impl.AuthMode))
+		}
+	case sql.AUTH_MODE_CUSTOM_HEADERS:
+		if len(impl.CustomHeaders) == 0 {
+			errs = append(errs, fmt.Errorf("customHeaders are required with auth mode %s", impl.AuthMode))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown auth mode %s", impl.AuthMode))
+	}
+	if !isSecretReferenceAllowed {
+		credentials := map[string]string{"password": impl.Password, "accessToken": impl.AccessToken}
+		for name, value := range impl.CustomHeaders {
+			credentials["customHeaders."+name] = value
+		}
+		errs = append(errs, validateNoSecretReferences(credentials)...)
+	}
+	if err := validateVersionFilter(impl.VersionFilter); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateSchedule(impl.Schedule, isDaemonMode); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func (impl *RegistryProvider) validate(mode ProvidersFileMode, isDaemonMode, isSecretReferenceAllowed bool) []error {
+	var errs []error
+	if len(strings.TrimSpace(impl.Id)) == 0 {
+		errs = append(errs, errors.New("id is required"))
+	}
+	if len(strings.TrimSpace(impl.RegistryUrl)) == 0 {
+		errs = append(errs, errors.New("registryUrl is required"))
+	}
+	switch impl.RegistryType {
+	case "", sql.REGISTRYTYPE_OTHER, sql.REGISTRYTYPE_DOCKER_HUB, sql.REGISTRYTYPE_GCR, sql.REGISTRYTYPE_ARTIFACT_REGISTRY:
+	case sql.REGISTRYTYPE_ECR:
+		if len(impl.AwsRegion) == 0 {
+			errs = append(errs, errors.New("awsRegion is required for ecr registries"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown registry type %s", impl.RegistryType))
+	}
+	switch impl.Connection {
+	case "", secureConnection, registry.INSECURE_CONNECTION:
+	case registry2.PLAIN_HTTP_CONNECTION:
+		// the orchestrator does not know the connection type, so it is not written into the shared table
+		if mode == ProvidersFileModeUpsert {
+			errs = append(errs, fmt.Errorf("connection %s is not supported in %s mode", impl.Connection, mode))
+		}
+	case registry.SECURE_WITH_CERT:
+		if len(impl.Cert) == 0 {
+			errs = append(errs, fmt.Errorf("cert is required with connection %s", impl.Connection))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown connection %s", impl.Connection))
+	}
+	if (len(impl.ClientCert) == 0) != (len(impl.ClientKey) == 0) {
+		errs = append(errs, errors.New("clientCert and clientKey must be set together"))
+	}
+	if len(impl.Charts) == 0 {
+		errs = append(errs, errors.New("charts are required"))
+	}
+	for i, chartConfig := range impl.Charts {
+		if chartConfig == nil || (len(chartConfig.Repositories) == 0 && !chartConfig.RepositoryDiscovery) {
+			errs = append(errs, fmt.Errorf("charts[%d]: repositories or repositoryDiscovery are required", i))
+			continue
+		}
+		if err := validateVersionFilter(chartConfig.VersionFilter); err != nil {
+			errs = append(errs, fmt.Errorf("charts[%d]: %w", i, err))
+		}
+	}
+	if !isSecretReferenceAllowed {
+		errs = append(errs, validateNoSecretReferences(map[string]string{
+			"password": impl.Password, "awsSecretAccessKey": impl.AwsSecretAccessKey, "clientKey": impl.ClientKey,
+		})...)
+	}
+	if err := validateSchedule(impl.Schedule, isDaemonMode); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// validateNoSecretReferences rejects the credentials by name which are secret references, sorted by name
+func validateNoSecretReferences(credentials map[string]string) []error {
+	names := make([]string, 0, len(credentials))
+	for name := range credentials {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var errs []error
+	for _, name := range names {
+		if secret.IsSecretReference(credentials[name]) {
+			errs = append(errs, fmt.Errorf("%s: secret references are rejected in %s mode as the orchestrator can not resolve them, set PROVIDERS_FILE_UPSERT_RESOLVED_SECRETS to write their resolved secrets into the tables", name, ProvidersFileModeUpsert))
+		}
+	}
+	return errs
+}
+
+func validateSchedule(schedule *Schedule, isDaemonMode bool) error {
+	if schedule == nil {
+		return nil
+	}
+	if !isDaemonMode {
+		return errors.New("schedule is only supported with DAEMON_MODE")
+	}
+	if schedule.IntervalInMinutes < 0 {
+		return errors.New("schedule interval must not be negative")
+	}
+	return nil
+}
+
+func validateHttpUrl(rawUrl string) error {
+	parsedUrl, err := url2.Parse(rawUrl)
+	if err != nil {
+		return err
+	}
+	if (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || len(parsedUrl.Host) == 0 {
+		return fmt.Errorf("%s is not an http or https url", parsedUrl.Redacted())
+	}
+	return nil
+}
+
+func validateVersionFilter(versionFilter *util.VersionFilterConfig) error {
+	versionFilterJson, err := marshalVersionFilter(versionFilter)
+	if err != nil {
+		return err
+	}
+	_, err = util.NewVersionFilter(versionFilterJson)
+	return err
+}
+
+func marshalVersionFilter(versionFilter *util.VersionFilterConfig) (string, error) {
+	if versionFilter == nil {
+		return "", nil
+	}
+	versionFilterJson, err := json.Marshal(versionFilter)
+	return string(versionFilterJson), err
+}
+
+// toChartRepo returns the chart repo of the provider, its id is the one set in the file
+func (impl *ChartRepoProvider) toChartRepo() *sql.ChartRepo {
+	// validated when the file was loaded
+	versionFilter, _ := marshalVersionFilter(impl.VersionFilter)
+	var customHeaders string
+	if len(impl.CustomHeaders) > 0 {
+		customHeadersJson, _ := json.Marshal(impl.CustomHeaders)
+		customHeaders = string(customHeadersJson)
+	}
+	now := time.Now()
+	return &sql.ChartRepo{
+		Id:                      impl.Id,
+		Name:                    impl.Name,
+		Url:                     impl.Url,
+		Active:                  true,
+		External:                true,
+		Username:                impl.Username,
+		Password:                impl.Password,
+		AllowInsecureConnection: impl.AllowInsecureConnection,
+		AuthMode:                impl.AuthMode,
+		AccessToken:             impl.AccessToken,
+		CustomHeaders:           customHeaders,
+		PassCredentialsAll:      impl.PassCredentialsAll,
+		MirrorUrls:              strings.Join(impl.MirrorUrls, ","),
+		VersionFilter:           versionFilter,
+		AuditLog:                sql.AuditLog{CreatedOn: now, CreatedBy: systemUserId, UpdatedOn: now, UpdatedBy: systemUserId},
+	}
+}
+
+// toDockerArtifactStore returns the store of the provider along with its chart pull configs
+func (impl *RegistryProvider) toDockerArtifactStore() *sql.DockerArtifactStore {
+	now := time.Now()
+	auditLog := sql.AuditLog{CreatedOn: now, CreatedBy: systemUserId, UpdatedOn: now, UpdatedBy: systemUserId}
+	store := &sql.DockerArtifactStore{
+		Id:                     impl.Id,
+		PluginId:               dockerRegistryPluginId,
+		RegistryURL:            impl.RegistryUrl,
+		RegistryType:           impl.RegistryType,
+		IsOCICompliantRegistry: true,
+		AWSAccessKeyId:         impl.AwsAccessKeyId,
+		AWSSecretAccessKey:     impl.AwsSecretAccessKey,
+		AWSRegion:              impl.AwsRegion,
+		Username:               impl.Username,
+		Password:               impl.Password,
+		Connection:             impl.Connection,
+		Cert:                   impl.Cert,
+		ClientCert:             impl.ClientCert,
+		ClientKey:              impl.ClientKey,
+		Active:                 true,
+		AuditLog:               auditLog,
+	}
+	if len(store.RegistryType) == 0 {
+		store.RegistryType = sql.REGISTRYTYPE_OTHER
+	}
+	if len(store.Connection) == 0 {
+		store.Connection = secureConnection
+	}
+	for _, chartConfig := range impl.Charts {
+		versionFilter, _ := marshalVersionFilter(chartConfig.VersionFilter)
+		store.OCIRegistryConfig = append(store.OCIRegistryConfig, &sql.OCIRegistryConfig{
+			DockerArtifactStoreId:        impl.Id,
+			RepositoryType:               sql.OCI_REGISRTY_REPO_TYPE_CHART,
+			RepositoryAction:             sql.STORAGE_ACTION_TYPE_PULL,
+			RepositoryList:               strings.Join(chartConfig.Repositories, ","),
+			IsChartPullActive:            true,
+			IsPublic:                     chartConfig.IsPublic,
+			IsRepositoryDiscoveryEnabled: chartConfig.RepositoryDiscovery,
+			RepositoryDiscoveryPrefix:    chartConfig.RepositoryDiscoveryPrefix,
+			VersionFilter:                versionFilter,
+			AuditLog:                     auditLog,
+		})
+	}
+	return store
+}
@@ -0,0 +1,210 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"github.com/devtron-labs/chart-sync/util"
+)
+
+func newTestProvidersFile() *ProvidersFile {
+	return &ProvidersFile{
+		ChartRepos: []*ChartRepoProvider{
+			{Id: 1, Name: "bitnami", Url: "https://charts.bitnami.com/bitnami", Username: "user", Password: "password"},
+		},
+		Registries: []*RegistryProvider{
+			{Id: "harbor", RegistryUrl: "harbor.example.com", Username: "robot", Password: "password", Charts: []*RegistryChartConfig{{Repositories: []string{"library/*"}}}},
+		},
+	}
+}
+
+func TestProvidersFile_Validate(t *testing.T) {
+	tests := []struct {
+		name                       string
+		change                     func(providersFile *ProvidersFile)
+		mode                       ProvidersFileMode
+		isDaemonMode               bool
+		isUpsertingResolvedSecrets bool
+		wantErrs                   []string
+	}{
+		{name: "valid in merge mode", mode: ProvidersFileModeMerge},
+		{name: "valid in upsert mode", mode: ProvidersFileModeUpsert},
+		{name: "valid in direct mode", mode: ProvidersFileModeDirect},
+		{
+			name:     "chart repo without id in direct mode",
+			change:   func(providersFile *ProvidersFile) { providersFile.ChartRepos[0].Id = 0 },
+			mode:     ProvidersFileModeDirect,
+			wantErrs: []string{"chartRepos[0] bitnami: id is required in direct mode"},
+		},
+		{
+			name: "duplicate chart repo names and registry ids",
+			change: func(providersFile *ProvidersFile) {
+				providersFile.ChartRepos = append(providersFile.ChartRepos, &ChartRepoProvider{Id: 2, Name: "bitnami", Url: "https://charts.example.com"})
+				providersFile.Registries = append(providersFile.Registries, providersFile.Registries[0])
+			},
+			mode:     ProvidersFileModeMerge,
+			wantErrs: []string{"chartRepos[1] bitnami: duplicate name", "registries[1] harbor: duplicate id"},
+		},
+		{
+			name: "invalid urls and auth mode",
+			change: func(providersFile *ProvidersFile) {
+				providersFile.ChartRepos[0].Url = "oci://charts.example.com"
+				providersFile.ChartRepos[0].MirrorUrls = []string{"mirror.example.com"}
+				providersFile.ChartRepos[0].AuthMode = sql.AUTH_MODE_ACCESS_TOKEN
+			},
+			mode:     ProvidersFileModeMerge,
+			wantErrs: []string{"url: oci://charts.example.com is not an http or https url", "mirrorUrls:", "accessToken is required with auth mode"},
+		},
+		{
+			name: "registry without charts and with unknown connection",
+			change: func(providersFile *ProvidersFile) {
+				providersFile.Registries[0].Charts = nil
+				providersFile.Registries[0].Connection = "tls"
+			},
+			mode:     ProvidersFileModeMerge,
+			wantErrs: []string{"registries[0] harbor: unknown connection tls", "registries[0] harbor: charts are required"},
+		},
+		{
+			name:     "plain http registry in upsert mode",
+			change:   func(providersFile *ProvidersFile) { providersFile.Registries[0].Connection = "plain-http" },
+			mode:     ProvidersFileModeUpsert,
+			wantErrs: []string{"connection plain-http is not supported in upsert mode"},
+		},
+		{
+			name: "invalid version filter",
+			change: func(providersFile *ProvidersFile) {
+				providersFile.Registries[0].Charts[0].VersionFilter = &util.VersionFilterConfig{Constraint: "not a constraint"}
+			},
+			mode:     ProvidersFileModeMerge,
+			wantErrs: []string{"registries[0] harbor: charts[0]:"},
+		},
+		{
+			name: "schedule outside daemon mode",
+			change: func(providersFile *ProvidersFile) {
+				providersFile.ChartRepos[0].Schedule = &Schedule{IntervalInMinutes: 60}
+			},
+			mode:     ProvidersFileModeMerge,
+			wantErrs: []string{"schedule is only supported with DAEMON_MODE"},
+		},
+		{
+			name: "schedule in daemon mode",
+			change: func(providersFile *ProvidersFile) {
+				providersFile.ChartRepos[0].Schedule = &Schedule{IntervalInMinutes: 60}
+			},
+			mode:         ProvidersFileModeMerge,
+			isDaemonMode: true,
+		},
+		{
+			name:   "secret references in merge mode",
+			change: setTestSecretReferences,
+			mode:   ProvidersFileModeMerge,
+		},
+		{
+			name:   "secret references in upsert mode",
+			change: setTestSecretReferences,
+			mode:   ProvidersFileModeUpsert,
+			wantErrs: []string{
+				"chartRepos[0] bitnami: accessToken: secret references are rejected in upsert mode",
+				"chartRepos[0] bitnami: customHeaders.X-Api-Key: secret references are rejected in upsert mode",
+				"chartRepos[0] bitnami: password: secret references are rejected in upsert mode",
+				"registries[0] harbor: awsSecretAccessKey: secret references are rejected in upsert mode",
+				"registries[0] harbor: clientKey: secret references are rejected in upsert mode",
+				"registries[0] harbor: password: secret references are rejected in upsert mode",
+			},
+		},
+		{
+			name:                       "secret references in upsert mode upserting resolved secrets",
+			change:                     setTestSecretReferences,
+			mode:                       ProvidersFileModeUpsert,
+			isUpsertingResolvedSecrets: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providersFile := newTestProvidersFile()
+			if tt.change != nil {
+				tt.change(providersFile)
+			}
+			err := providersFile.Validate(tt.mode, tt.isDaemonMode, tt.isUpsertingResolvedSecrets)
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want none", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() error = nil, want %v", tt.wantErrs)
+			}
+			for _, wantErr := range tt.wantErrs {
+				if !strings.Contains(err.Error(), wantErr) {
+					t.Errorf("Validate() error = %v, want it to contain %q", err, wantErr)
+				}
+			}
+			if errCount := len(strings.Split(err.Error(), "\n")); errCount != len(tt.wantErrs) {
+				t.Errorf("Validate() returned %d errors, want %d: %v", errCount, len(tt.wantErrs), err)
+			}
+		})
+	}
+}
+
+// setTestSecretReferences replaces the credentials of the providers with secret references, leaving a plaintext one
+func setTestSecretReferences(providersFile *ProvidersFile) {
+	chartRepo := providersFile.ChartRepos[0]
+	chartRepo.Password = "env://CHART_SYNC_PASSWORD"
+	chartRepo.AccessToken = "file:///etc/secrets/token"
+	chartRepo.CustomHeaders = map[string]string{"X-Api-Key": "k8s://charts/api-key/key", "X-Team": "charts"}
+	registryProvider := providersFile.Registries[0]
+	registryProvider.Password = "enc://v1.key.ciphertext"
+	registryProvider.AwsSecretAccessKey = "env://CHART_SYNC_AWS_SECRET"
+	registryProvider.ClientCert = "cert"
+	registryProvider.ClientKey = "file:///etc/secrets/client.key"
+}
+
+func TestLoadProvidersFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name: "valid file",
+			content: `chartRepos:
+  - id: 1
+    name: bitnami
+    url: https://charts.bitnami.com/bitnami
+registries:
+  - id: harbor
+    registryUrl: harbor.example.com
+    charts:
+      - repositories: [library/*]
+`,
+		},
+		{
+			name: "unknown field",
+			content: `chartRepos:
+  - id: 1
+    name: bitnami
+    url: https://charts.bitnami.com/bitnami
+    keepLatest: 10
+`,
+			wantErr: true,
+		},
+		{name: "invalid yaml", content: "chartRepos: [", wantErr: true},
+		{name: "invalid provider", content: "chartRepos:\n  - name: bitnami\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "providers.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadProvidersFile(path, ProvidersFileModeMerge, false, false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadProvidersFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
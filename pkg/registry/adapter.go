@@ -6,7 +6,15 @@ import (
 	"github.com/devtron-labs/common-lib/utils/remoteConnection/bean"
 )
 
-func NewToRegistryConfig(store *sql.DockerArtifactStore) (*registry.Configuration, error) {
+// PLAIN_HTTP_CONNECTION is the connection type of registries served over plain HTTP, like a local registry:2 or an
+// air-gapped mirror. Unlike insecure, which skips TLS verification and only falls back to http for registries answering
+// https with plain http, https is never tried and nothing is encrypted, not even credentials.
+// The orchestrator does not offer this connection type, it is only set for registries declared in the providers file.
+const PLAIN_HTTP_CONNECTION = "plain-http"
+
+// NewToRegistryConfig returns the registry configuration of the store for pulling charts with the given config of the
+// store, which can be nil when no config applies
+func NewToRegistryConfig(store *sql.DockerArtifactStore, ociRegistryConfig *sql.OCIRegistryConfig) (*registry.Configuration, error) {
 	remoteConnectionConfig := &bean.RemoteConnectionConfigBean{}
 	if store.RemoteConnectionConfig != nil && store.RemoteConnectionConfigId > 0 {
 		remoteConnectionConfig.ConnectionMethod = bean.RemoteConnectionMethod(store.RemoteConnectionConfig.ConnectionMethod)
@@ -24,13 +32,13 @@ func NewToRegistryConfig(store *sql.DockerArtifactStore) (*registry.Configuratio
 			}
 		}
 	}
-	var certificatePath string
-	var err error
-	if store.Connection == registry.SECURE_WITH_CERT {
-		certificatePath, err = registry.CreateCertificateFile(store.Id, store.Cert)
-		if err != nil {
-			return nil, err
-		}
+	var isPublicRegistry bool
+	if ociRegistryConfig != nil {
+		isPublicRegistry = ociRegistryConfig.IsPublic
+	}
+	if !hasCredentials(store) {
+		// registries without credentials are pulled from anonymously instead of logging in with empty basic auth
+		isPublicRegistry = true
 	}
 	return &registry.Configuration{
 		RegistryId:                store.Id,
@@ -42,9 +50,17 @@ func NewToRegistryConfig(store *sql.DockerArtifactStore) (*registry.Configuratio
 		AwsRegion:                 store.AWSRegion,
 		RegistryConnectionType:    store.Connection,
 		RegistryCertificateString: store.Cert,
-		RegistryCAFilePath:        certificatePath,
 		RegistryType:              string(store.RegistryType),
-		IsPublicRegistry:          store.OCIRegistryConfig[0].IsPublic,
+		IsPublicRegistry:          isPublicRegistry,
 		RemoteConnectionConfig:    remoteConnectionConfig,
 	}, nil
 }
+
+// hasCredentials reports whether the store has credentials to log in with. ECR stores always have, as the aws sdk falls
+// back to the credentials of the environment when no access key is configured.
+func hasCredentials(store *sql.DockerArtifactStore) bool {
+	if store.RegistryType == sql.REGISTRYTYPE_ECR {
+		return true
+	}
+	return len(store.Username) > 0 || len(store.Password) > 0
+}
@@ -0,0 +1,312 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/containerd/containerd/remotes/docker"
+	"github.com/devtron-labs/common-lib/helmLib/registry"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	helmRegistry "helm.sh/helm/v3/pkg/registry"
+	"io"
+	"net/http"
+	"net/url"
+	"oras.land/oras-go/pkg/registry/remote/auth"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	catalogPageSize  = 1000
+	catalogScope     = "registry:catalog:*"
+	maxMetadataBytes = 4 * 1024 * 1024
+
+	dockerHubHost      = "docker.io"
+	dockerHubIndexHost = "index.docker.io"
+	dockerHubAPIHost   = "registry-1.docker.io"
+)
+
+// OCIClient talks to the OCI distribution API of a registry for the operations the helm registry client does not cover,
+// like listing the repositories of a registry and reading manifests.
+type OCIClient struct {
+	registryHost string
+	apiHost      string // host serving the distribution API of the registry host, see GetRegistryAPIHost
+	scheme       string // http for registries with the plain http connection type, https otherwise
+	client       *auth.Client
+}
+
+// NewOCIClient returns an OCIClient for the registry of the configuration. Username and password of the configuration
+// are used as is, so for registries like ECR which exchange credentials for a token they should have been replaced with
+// the token from the CredentialProvider.
+// Public registries and registries without credentials are accessed anonymously, answering bearer challenges with
+// anonymous tokens and never with empty basic auth. Tokens are cached per scope, i.e. per repository, for the lifetime of
+// the client.
+// Registries with the insecure connection type are tried over https first, falling back to http when they answer https
+// requests with plain http.
+func NewOCIClient(config *registry.Configuration, httpClient *http.Client) *OCIClient {
+	credential := auth.EmptyCredential
+	if !config.IsPublicRegistry && (len(config.Username) > 0 || len(config.Password) > 0) {
+		credential = auth.Credential{
+			Username: config.Username,
+			Password: config.Password,
+		}
+	}
+	scheme := "https"
+	switch config.RegistryConnectionType {
+	case PLAIN_HTTP_CONNECTION:
+		scheme = "http"
+	case registry.INSECURE_CONNECTION:
+		httpClient = newHTTPFallbackClient(httpClient)
+	}
+	registryHost := GetRegistryHost(config.RegistryUrl)
+	return &OCIClient{
+		registryHost: registryHost,
+		apiHost:      GetRegistryAPIHost(registryHost),
+		scheme:       scheme,
+		client: &auth.Client{
+			Client: httpClient,
+			Cache:  auth.NewCache(),
+			Credential: func(ctx context.Context, registry string) (auth.Credential, error) {
+				return credential, nil
+			},
+		},
+	}
+}
+
+// NewRegistryClient returns a helm registry client for the registry of the configuration. Instead of logging in, which
+// takes TLS material as file paths and stores credentials in the helm credentials file, the client resolves references
+// through the given http client and answers auth challenges of the registry host with the username and password of the
+// configuration, unless the registry is public or they are empty.
+// Registries with the insecure connection type are tried over https first, falling back to http when they answer https
+// requests with plain http, like the helm client with plain http did for them.
+func NewRegistryClient(config *registry.Configuration, httpClient *http.Client) (*helmRegistry.Client, error) {
+	// containerd resolves Docker Hub to its API host before asking for credentials
+	registryAPIHost := GetRegistryAPIHost(GetRegistryHost(config.RegistryUrl))
+	hasCredentials := !config.IsPublicRegistry && (len(config.Username) > 0 || len(config.Password) > 0)
+	authorizer := docker.NewDockerAuthorizer(
+		docker.WithAuthClient(httpClient),
+		docker.WithAuthCreds(func(host string) (string, string, error) {
+			if !hasCredentials || GetRegistryAPIHost(host) != registryAPIHost {
+				return "", "", nil
+			}
+			return config.Username, config.Password, nil
+		}),
+	)
+	if config.RegistryConnectionType == registry.INSECURE_CONNECTION {
+		httpClient = newHTTPFallbackClient(httpClient)
+	}
+	registryOptions := []docker.RegistryOpt{docker.WithClient(httpClient), docker.WithAuthorizer(authorizer)}
+	clientOptions := []helmRegistry.ClientOption{helmRegistry.ClientOptHTTPClient(httpClient)}
+	if config.RegistryConnectionType == PLAIN_HTTP_CONNECTION {
+		registryOptions = append(registryOptions, docker.WithPlainHTTP(docker.MatchAllHosts))
+		clientOptions = append(clientOptions, helmRegistry.ClientOptPlainHTTP())
+	}
+	resolver := docker.NewResolver(docker.ResolverOptions{
+		Hosts: docker.ConfigureDefaultRegistries(registryOptions...),
+	})
+	clientOptions = append(clientOptions, helmRegistry.ClientOptResolver(resolver))
+	return helmRegistry.NewClient(clientOptions...)
+}
+
+// GetRegistryHost returns the host of a registry url which can be with or without scheme and with or without a path
+func GetRegistryHost(registryUrl string) string {
+	registryUrl = strings.TrimSpace(registryUrl)
+	if parsedUrl, err := url.Parse(registryUrl); err == nil && len(parsedUrl.Host) > 0 {
+		return parsedUrl.Host
+	}
+	registryHost, _, _ := strings.Cut(registryUrl, "/")
+	return registryHost
+}
+
+// GetRegistryAPIHost returns the host serving the distribution API of a registry host, which is registry-1.docker.io
+// for Docker Hub like containerd resolves it, and the registry host itself for any other registry
+func GetRegistryAPIHost(registryHost string) string {
+	if registryHost == dockerHubHost || registryHost == dockerHubIndexHost {
+		return dockerHubAPIHost
+	}
+	return registryHost
+}
+
+// newHTTPFallbackClient returns a copy of the http client retrying https requests over http for hosts which answer
+// them with plain http
+func newHTTPFallbackClient(httpClient *http.Client) *http.Client {
+	transport := httpClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	fallbackClient := *httpClient
+	fallbackClient.Transport = docker.NewHTTPFallback(transport)
+	return &fallbackClient
+}
+
+// Reference returns the oci:// reference of a tag of a repository of the registry
+func (impl *OCIClient) Reference(repository, tag string) string {
+	return fmt.Sprintf("%s://%s/%s:%s", helmRegistry.OCIScheme, impl.registryHost, repository, tag)
+}
+
+// Catalog lists all repositories of the registry starting with the given prefix using the _catalog endpoint
+func (impl *OCIClient) Catalog(ctx context.Context, prefix string) ([]string, error) {
+	ctx = auth.WithScopes(ctx, catalogScope)
+	repositories := make([]string, 0)
+	nextUrl := fmt.Sprintf("%s://%s/v2/_catalog?n=%s", impl.scheme, impl.apiHost, strconv.Itoa(catalogPageSize))
+	for len(nextUrl) > 0 {
+		var page struct {
+			Repositories []string `json:"repositories"`
+		}
+		resp, err := impl.get(ctx, nextUrl, "")
+		if err != nil {
+			return nil, err
+		}
+		err = json.NewDecoder(io.LimitReader(resp.Body, maxMetadataBytes)).Decode(&page)
+		nextUrl = impl.nextLink(resp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode catalog response of registry %s: %w", impl.registryHost, err)
+		}
+		for _, repository := range page.Repositories {
+			if strings.HasPrefix(repository, prefix) {
+				repositories = append(repositories, repository)
+			}
+		}
+	}
+	return repositories, nil
+}
+
+// Manifest is an OCI image manifest along with the last modified time the registry reported for it, if any
+type Manifest struct {
+	ocispec.Manifest
+	LastModified time.Time
+}
+
+// FetchManifest fetches the OCI image manifest of the given tag or digest of a repository
+func (impl *OCIClient) FetchManifest(ctx context.Context, repository, reference string) (*Manifest, error) {
+	ctx = auth.WithScopes(ctx, auth.ScopeRepository(repository, auth.ActionPull))
+	manifestUrl := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", impl.scheme, impl.apiHost, repository, reference)
+	resp, err := impl.get(ctx, manifestUrl, ocispec.MediaTypeImageManifest)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	manifest := &Manifest{}
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxMetadataBytes)).Decode(&manifest.Manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode manifest %s:%s: %w", repository, reference, err)
+	}
+	if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		manifest.LastModified = lastModified
+	}
+	return manifest, nil
+}
+
+// Tags lists the tags of a repository sorting after last, pageSize tags per request following the Link header for next
+// pages. All tags of the repository are listed when last is empty.
+func (impl *OCIClient) Tags(ctx context.Context, repository, last string, pageSize int) ([]string, error) {
+	ctx = auth.WithScopes(ctx, auth.ScopeRepository(repository, auth.ActionPull))
+	query := url.Values{}
+	query.Set("n", strconv.Itoa(pageSize))
+	if len(last) > 0 {
+		query.Set("last", last)
+	}
+	tags := make([]string, 0)
+	nextUrl := fmt.Sprintf("%s://%s/v2/%s/tags/list?%s", impl.scheme, impl.apiHost, repository, query.Encode())
+	for len(nextUrl) > 0 {
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		resp, err := impl.get(ctx, nextUrl, "")
+		if err != nil {
+			return nil, err
+		}
+		err = json.NewDecoder(io.LimitReader(resp.Body, maxMetadataBytes)).Decode(&page)
+		nextUrl = impl.nextLink(resp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode tags of repository %s: %w", repository, err)
+		}
+		tags = append(tags, page.Tags...)
+	}
+	return tags, nil
+}
+
+// FetchBlob fetches a blob of a repository, like the config or a layer of a manifest, verifying it against the digest
+// of the descriptor
+func (impl *OCIClient) FetchBlob(ctx context.Context, repository string, descriptor ocispec.Descriptor) ([]byte, error) {
+	ctx = auth.WithScopes(ctx, auth.ScopeRepository(repository, auth.ActionPull))
+	blobUrl := fmt.Sprintf("%s://%s/v2/%s/blobs/%s", impl.scheme, impl.apiHost, repository, descriptor.Digest)
+	resp, err := impl.get(ctx, blobUrl, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	blob, err := io.ReadAll(io.LimitReader(resp.Body, descriptor.Size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s of repository %s: %w", descriptor.Digest, repository, err)
+	}
+	if int64(len(blob)) != descriptor.Size || descriptor.Digest.Validate() != nil || descriptor.Digest.Algorithm().FromBytes(blob) != descriptor.Digest {
+		return nil, fmt.Errorf("blob %s of repository %s does not match its descriptor", descriptor.Digest, repository)
+	}
+	return blob, nil
+}
+
+// IsHelmChartRepository checks the manifest of any tag of the repository for the helm chart config media type
+func (impl *OCIClient) IsHelmChartRepository(ctx context.Context, repository string) (bool, error) {
+	ctx = auth.WithScopes(ctx, auth.ScopeRepository(repository, auth.ActionPull))
+	tagListUrl := fmt.Sprintf("%s://%s/v2/%s/tags/list?n=1", impl.scheme, impl.apiHost, repository)
+	resp, err := impl.get(ctx, tagListUrl, "")
+	if err != nil {
+		return false, err
+	}
+	var page struct {
+		Tags []string `json:"tags"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxMetadataBytes)).Decode(&page)
+	resp.Body.Close()
+	if err != nil {
+		return false, fmt.Errorf("failed to decode tags of repository %s: %w", repository, err)
+	}
+	if len(page.Tags) == 0 {
+		return false, nil
+	}
+	manifest, err := impl.FetchManifest(ctx, repository, page.Tags[0])
+	if err != nil {
+		return false, err
+	}
+	return manifest.Config.MediaType == helmRegistry.ConfigMediaType, nil
+}
+
+func (impl *OCIClient) get(ctx context.Context, requestUrl, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(accept) > 0 {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := impl.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: unexpected status code %d", req.Method, requestUrl, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// nextLink returns the absolute url of the next page from the Link header, empty when there are no more pages
+func (impl *OCIClient) nextLink(resp *http.Response) string {
+	link := resp.Header.Get("Link")
+	if len(link) == 0 || link[0] != '<' {
+		return ""
+	}
+	link, _, found := strings.Cut(link[1:], ">")
+	if !found {
+		return ""
+	}
+	linkUrl, err := resp.Request.URL.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return linkUrl.String()
+}
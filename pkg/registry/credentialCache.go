@@ -0,0 +1,236 @@
+package registry
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/devtron-labs/chart-sync/internals"
+	logger2 "github.com/devtron-labs/chart-sync/internals/logger"
+	"github.com/devtron-labs/common-lib/helmLib/registry"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RegistryCredential is the username and password to access a registry with, ExpiresAt is zero for credentials which
+// do not expire
+type RegistryCredential struct {
+	Username    string    `json:"username"`
+	Password    string    `json:"password"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	Fingerprint string    `json:"fingerprint"` // hash of the registry configuration the credential was issued for
+}
+
+// CredentialProvider returns the credentials to access registries with, exchanging the configured credentials for
+// short-lived tokens for registries like ECR
+type CredentialProvider interface {
+	GetCredential(config *registry.Configuration) (*RegistryCredential, error)
+}
+
+// CredentialProviderImpl caches short-lived registry tokens by registry id until shortly before they expire. The cache is
+// held in memory, which spans runs in daemon mode, and optionally in a file encrypted with AES-GCM to span runs of jobs.
+type CredentialProviderImpl struct {
+	logger        *zap.SugaredLogger
+	refreshBefore time.Duration
+	cacheFile     string
+	cacheCipher   cipher.AEAD
+	credentials   map[string]*RegistryCredential
+	mutex         sync.Mutex
+}
+
+func NewCredentialProviderImpl(logger *zap.SugaredLogger, configuration *internals.Configuration) *CredentialProviderImpl {
+	impl := &CredentialProviderImpl{
+		logger:        logger.Named(logger2.ComponentRegistry),
+		refreshBefore: time.Duration(configuration.RegistryCredentialRefreshBeforeInMinutes) * time.Minute,
+		credentials:   make(map[string]*RegistryCredential),
+	}
+	if len(configuration.RegistryCredentialCacheFile) > 0 {
+		cacheCipher, err := newCacheCipher(configuration.RegistryCredentialCacheKey)
+		if err != nil {
+			// tokens are never written to disk unencrypted
+			impl.logger.Errorw("error in creating cipher of registry credential cache file, caching in memory only", "err", err)
+		} else {
+			impl.cacheFile = configuration.RegistryCredentialCacheFile
+			impl.cacheCipher = cacheCipher
+			impl.loadCacheFile()
+		}
+	}
+	return impl
+}
+
+func newCacheCipher(cacheKey string) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(cacheKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid registry credential cache key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, errors.New("registry credential cache key must be 32 bytes encoded in base64")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// GetCredential returns the credential of the registry. Configured credentials are returned as is, except for ECR
+// registries whose authorization token is cached and fetched again once it is about to expire.
+func (impl *CredentialProviderImpl) GetCredential(config *registry.Configuration) (*RegistryCredential, error) {
+	if config.RegistryType != registry.REGISTRY_TYPE_ECR {
+		return &RegistryCredential{
+			Username: config.Username,
+			Password: getConfiguredPassword(config),
+		}, nil
+	}
+	fingerprint := getCredentialFingerprint(config)
+	impl.mutex.Lock()
+	defer impl.mutex.Unlock()
+	if credential, ok := impl.credentials[config.RegistryId]; ok && credential.Fingerprint == fingerprint &&
+		time.Until(credential.ExpiresAt) > impl.refreshBefore {
+		return credential, nil
+	}
+	credential, err := getECRCredential(config)
+	if err != nil {
+		impl.logger.Errorw("error in getting ecr authorization token", "registryName", config.RegistryId, "err", err)
+		return nil, err
+	}
+	credential.Fingerprint = fingerprint
+	impl.credentials[config.RegistryId] = credential
+	impl.logger.Infow("fetched ecr authorization token", "registryName", config.RegistryId, "expiresAt", credential.ExpiresAt)
+	impl.saveCacheFile()
+	return credential, nil
+}
+
+// getConfiguredPassword returns the configured password, without the quotes of json keys of GCR and artifact registry
+func getConfiguredPassword(config *registry.Configuration) string {
+	password := config.Password
+	if (config.RegistryType == registry.REGISTRYTYPE_GCR || config.RegistryType == registry.REGISTRYTYPE_ARTIFACT_REGISTRY) && config.Username == registry.JSON_KEY_USERNAME {
+		password = strings.TrimPrefix(strings.TrimSuffix(password, "'"), "'")
+	}
+	return password
+}
+
+func getCredentialFingerprint(config *registry.Configuration) string {
+	hash := sha256.Sum256([]byte(strings.Join([]string{config.RegistryUrl, config.AwsRegion, config.AwsAccessKey, config.AwsSecretKey}, "\n")))
+	return hex.EncodeToString(hash[:])
+}
+
+// getECRCredential exchanges the aws credentials of the configuration for an ECR authorization token the same way the
+// common-lib registry client logs in to ECR. Its exchange is not exported and drops the expiry of the token, which the
+// cache needs, so it is done here until common-lib exposes it.
+func getECRCredential(config *registry.Configuration) (*RegistryCredential, error) {
+	var creds *credentials.Credentials
+	if len(config.AwsAccessKey) == 0 || len(config.AwsSecretKey) == 0 {
+		sess, err := session.NewSession(&aws.Config{
+			Region: &config.AwsRegion,
+		})
+		if err != nil {
+			return nil, err
+		}
+		creds = ec2rolecreds.NewCredentials(sess)
+	} else {
+		creds = credentials.NewStaticCredentials(config.AwsAccessKey, config.AwsSecretKey, "")
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Region:      &config.AwsRegion,
+		Credentials: creds,
+	})
+	if err != nil {
+		return nil, err
+	}
+	authData, err := ecr.New(sess).GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return nil, err
+	}
+	if len(authData.AuthorizationData) == 0 {
+		return nil, errors.New("no authorization data returned by ecr")
+	}
+	decodedToken, err := base64.StdEncoding.DecodeString(aws.StringValue(authData.AuthorizationData[0].AuthorizationToken))
+	if err != nil {
+		return nil, err
+	}
+	username, password, found := strings.Cut(string(decodedToken), ":")
+	if !found {
+		return nil, errors.New("invalid authorization token returned by ecr")
+	}
+	return &RegistryCredential{
+		Username:  username,
+		Password:  password,
+		ExpiresAt: aws.TimeValue(authData.AuthorizationData[0].ExpiresAt),
+	}, nil
+}
+
+func (impl *CredentialProviderImpl) loadCacheFile() {
+	encrypted, err := os.ReadFile(impl.cacheFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	} else if err != nil {
+		impl.logger.Errorw("error in reading registry credential cache file", "file", impl.cacheFile, "err", err)
+		return
+	}
+	nonceSize := impl.cacheCipher.NonceSize()
+	if len(encrypted) < nonceSize {
+		impl.logger.Errorw("ignoring invalid registry credential cache file", "file", impl.cacheFile)
+		return
+	}
+	decrypted, err := impl.cacheCipher.Open(nil, encrypted[:nonceSize], encrypted[nonceSize:], nil)
+	if err != nil {
+		// the key was rotated or the file is corrupt, tokens are fetched again
+		impl.logger.Errorw("error in decrypting registry credential cache file", "file", impl.cacheFile, "err", err)
+		return
+	}
+	err = json.Unmarshal(decrypted, &impl.credentials)
+	if err != nil {
+		impl.logger.Errorw("error in decoding registry credential cache file", "file", impl.cacheFile, "err", err)
+		impl.credentials = make(map[string]*RegistryCredential)
+	}
+}
+
+func (impl *CredentialProviderImpl) saveCacheFile() {
+	if len(impl.cacheFile) == 0 {
+		return
+	}
+	decrypted, err := json.Marshal(impl.credentials)
+	if err != nil {
+		impl.logger.Errorw("error in encoding registry credential cache", "err", err)
+		return
+	}
+	nonce := make([]byte, impl.cacheCipher.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		impl.logger.Errorw("error in generating nonce for registry credential cache", "err", err)
+		return
+	}
+	encrypted := impl.cacheCipher.Seal(nonce, nonce, decrypted, nil)
+	// written to a temp file first so that an interrupted write never leaves a truncated cache behind
+	tempFile, err := os.CreateTemp(filepath.Dir(impl.cacheFile), filepath.Base(impl.cacheFile)+".*")
+	if err != nil {
+		impl.logger.Errorw("error in writing registry credential cache file", "file", impl.cacheFile, "err", err)
+		return
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.Write(encrypted)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), impl.cacheFile)
+	}
+	if err != nil {
+		impl.logger.Errorw("error in writing registry credential cache file", "file", impl.cacheFile, "err", err)
+	}
+}
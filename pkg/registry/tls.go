@@ -0,0 +1,75 @@
+package registry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"github.com/devtron-labs/common-lib/helmLib/registry"
+	"net/http"
+	"time"
+)
+
+// NewTLSConfig builds a tls.Config in memory from PEM encoded certificates. The CA certificate is trusted in addition
+// to the system roots, the client certificate and key are presented to registries requiring mutual TLS. Empty values
+// are skipped.
+func NewTLSConfig(caCert, clientCert, clientKey string, insecureSkipVerify bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+	if len(caCert) > 0 {
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM([]byte(caCert)) {
+			return nil, errors.New("no valid PEM encoded CA certificate found")
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	if len(clientCert) > 0 || len(clientKey) > 0 {
+		certificate, err := tls.X509KeyPair([]byte(clientCert), []byte(clientKey))
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// GetTlsConfig returns the tls.Config of the registry of the store, trusting its certificate when the connection is
+// secure with certificate, skipping verification when the connection is insecure and presenting its client certificate
+// when it has one
+func GetTlsConfig(store *sql.DockerArtifactStore) (*tls.Config, error) {
+	var caCert string
+	if store.Connection == registry.SECURE_WITH_CERT {
+		caCert = store.Cert
+	}
+	return NewTLSConfig(caCert, store.ClientCert, store.ClientKey, store.Connection == registry.INSECURE_CONNECTION)
+}
+
+// GetHttpClient returns an http client for the registry of the store using the tls.Config from GetTlsConfig, which is
+// used for logins as well as pulls
+func GetHttpClient(store *sql.DockerArtifactStore) (*http.Client, error) {
+	tlsConfig, err := GetTlsConfig(store)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}, nil
+}
+
+// GetCertificateExpiry returns the time the first certificate of the PEM encoded certificate chain expires at
+func GetCertificateExpiry(pemCert string) (time.Time, error) {
+	block, _ := pem.Decode([]byte(pemCert))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return time.Time{}, errors.New("no valid PEM encoded certificate found")
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return certificate.NotAfter, nil
+}
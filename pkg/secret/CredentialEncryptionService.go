@@ -0,0 +1,203 @@
+package secret
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	logger2 "github.com/devtron-labs/chart-sync/internals/logger"
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"go.uber.org/zap"
+	"strings"
+)
+
+// ReEncryptOptions are the options of the reencrypt-credentials command
+type ReEncryptOptions struct {
+	// DryRun only logs the rows whose credentials would be encrypted
+	DryRun bool
+	// EncryptCleartext encrypts cleartext credentials too. The orchestrator reads the same rows and can not use enc://
+	// credentials unless it decrypts them with the same key encryption key, so this is only safe once it does.
+	EncryptCleartext bool
+}
+
+// CredentialEncryptionService encrypts the credentials stored in the chart provider tables
+type CredentialEncryptionService interface {
+	// ReEncryptCredentials encrypts the already encrypted credentials of the chart repos and registries chart-sync syncs
+	// and of their remote connection configs again with a new data key wrapped by the current key encryption key, and
+	// their cleartext credentials only when asked to. Credentials whose data key is wrapped by the previous key
+	// encryption key are wrapped by the current one afterwards, which completes a rotation of the key encryption key. Secret references like file://, env:// and k8s:// are left as they
+	// are. All rows are updated in one transaction.
+	ReEncryptCredentials(options ReEncryptOptions) error
+}
+
+type CredentialEncryptionServiceImpl struct {
+	logger                        *zap.SugaredLogger
+	envelopeSecretSource          *EnvelopeSecretSource
+	chartRepoRepository           sql.ChartRepoRepository
+	dockerArtifactStoreRepository sql.DockerArtifactStoreRepository
+	remoteConnectionRepository    sql.RemoteConnectionRepository
+	providerRepository            sql.ProviderRepository
+}
+
+func NewCredentialEncryptionServiceImpl(logger *zap.SugaredLogger,
+	envelopeSecretSource *EnvelopeSecretSource,
+	chartRepoRepository sql.ChartRepoRepository,
+	dockerArtifactStoreRepository sql.DockerArtifactStoreRepository,
+	remoteConnectionRepository sql.RemoteConnectionRepository,
+	providerRepository sql.ProviderRepository,
+) *CredentialEncryptionServiceImpl {
+	return &CredentialEncryptionServiceImpl{
+		logger:                        logger.Named(logger2.ComponentSync),
+		envelopeSecretSource:          envelopeSecretSource,
+		chartRepoRepository:           chartRepoRepository,
+		dockerArtifactStoreRepository: dockerArtifactStoreRepository,
+		remoteConnectionRepository:    remoteConnectionRepository,
+		providerRepository:            providerRepository,
+	}
+}
+
+func (impl *CredentialEncryptionServiceImpl) ReEncryptCredentials(options ReEncryptOptions) error {
+	// every row is encrypted before any is updated, so that a failed row is logged along with all other failed rows
+	var isAnyRowFailed bool
+	remoteConnectionConfigIds := make(map[int]bool)
+	chartRepos, err := impl.chartRepoRepository.GetAll()
+	if err != nil {
+		impl.logger.Errorw("error in getting chart repos", "err", err)
+		return err
+	}
+	encryptedChartRepos := make([]*sql.ChartRepo, 0)
+	for _, chartRepo := range chartRepos {
+		if chartRepo.RemoteConnectionConfigId > 0 {
+			remoteConnectionConfigIds[chartRepo.RemoteConnectionConfigId] = true
+		}
+		isEncrypted, err := impl.reEncryptAll(options, &chartRepo.Password, &chartRepo.AccessToken)
+		if err == nil {
+			var isCustomHeadersEncrypted bool
+			isCustomHeadersEncrypted, err = impl.reEncryptCustomHeaders(options, &chartRepo.CustomHeaders)
+			isEncrypted = isEncrypted || isCustomHeadersEncrypted
+		}
+		if err != nil {
+			impl.logger.Errorw("error in encrypting credentials of chart repo", "repo", chartRepo.Name, "err", err)
+			isAnyRowFailed = true
+		} else if isEncrypted {
+			impl.logger.Infow("encrypting credentials of chart repo", "repo", chartRepo.Name, "dryRun", options.DryRun)
+			encryptedChartRepos = append(encryptedChartRepos, chartRepo)
+		}
+	}
+	stores, err := impl.dockerArtifactStoreRepository.FindAllChartProviders()
+	if err != nil {
+		impl.logger.Errorw("error in getting docker artifact stores", "err", err)
+		return err
+	}
+	encryptedStores := make([]*sql.DockerArtifactStore, 0)
+	for _, store := range stores {
+		if store.RemoteConnectionConfigId > 0 {
+			remoteConnectionConfigIds[store.RemoteConnectionConfigId] = true
+		}
+		isEncrypted, err := impl.reEncryptAll(options, &store.Password, &store.AWSSecretAccessKey, &store.ClientKey)
+		if err != nil {
+			impl.logger.Errorw("error in encrypting credentials of registry", "registryName", store.Id, "err", err)
+			isAnyRowFailed = true
+		} else if isEncrypted {
+			impl.logger.Infow("encrypting credentials of registry", "registryName", store.Id, "dryRun", options.DryRun)
+			encryptedStores = append(encryptedStores, store)
+		}
+	}
+	encryptedRemoteConnectionConfigs := make([]*sql.RemoteConnectionConfig, 0)
+	for remoteConnectionConfigId := range remoteConnectionConfigIds {
+		remoteConnectionConfig, err := impl.remoteConnectionRepository.GetById(remoteConnectionConfigId)
+		if err != nil {
+			impl.logger.Errorw("error in getting remote connection config", "remoteConnectionConfigId", remoteConnectionConfigId, "err", err)
+			return err
+		}
+		if remoteConnectionConfig.Id == 0 {
+			continue
+		}
+		isEncrypted, err := impl.reEncryptAll(options, &remoteConnectionConfig.SSHPassword, &remoteConnectionConfig.SSHAuthKey)
+		if err != nil {
+			impl.logger.Errorw("error in encrypting credentials of remote connection config", "remoteConnectionConfigId", remoteConnectionConfigId, "err", err)
+			isAnyRowFailed = true
+		} else if isEncrypted {
+			impl.logger.Infow("encrypting credentials of remote connection config", "remoteConnectionConfigId", remoteConnectionConfigId, "dryRun", options.DryRun)
+			encryptedRemoteConnectionConfigs = append(encryptedRemoteConnectionConfigs, remoteConnectionConfig)
+		}
+	}
+	if isAnyRowFailed {
+		return errors.New("failed to encrypt credentials of some rows, no credentials were updated")
+	}
+	if !options.DryRun {
+		err = impl.providerRepository.UpdateCredentials(encryptedChartRepos, encryptedStores, encryptedRemoteConnectionConfigs)
+		if err != nil {
+			impl.logger.Errorw("error in updating encrypted credentials", "err", err)
+			return err
+		}
+	}
+	impl.logger.Infow("encrypted credentials", "dryRun", options.DryRun, "chartRepos", len(encryptedChartRepos),
+		"dockerArtifactStores", len(encryptedStores), "remoteConnectionConfigs", len(encryptedRemoteConnectionConfigs))
+	return nil
+}
+
+// reEncryptCustomHeaders encrypts the header values of the json object of custom headers like reEncryptAll, keeping
+// the header names readable
+func (impl *CredentialEncryptionServiceImpl) reEncryptCustomHeaders(options ReEncryptOptions, customHeaders *string) (bool, error) {
+	if len(*customHeaders) == 0 {
+		return false, nil
+	}
+	headers := make(map[string]string)
+	err := json.Unmarshal([]byte(*customHeaders), &headers)
+	if err != nil {
+		// the value is left out of the error as it holds the credentials
+		return false, errors.New("custom headers are not a json object of header name to header value")
+	}
+	var isEncrypted bool
+	for name, value := range headers {
+		isValueEncrypted, err := impl.reEncryptAll(options, &value)
+		if err != nil {
+			return false, fmt.Errorf("header %s: %w", name, err)
+		}
+		if isValueEncrypted {
+			headers[name] = value
+			isEncrypted = true
+		}
+	}
+	if !isEncrypted {
+		return false, nil
+	}
+	encryptedHeaders, err := json.Marshal(headers)
+	if err != nil {
+		return false, err
+	}
+	*customHeaders = string(encryptedHeaders)
+	return true, nil
+}
+
+// reEncryptAll encrypts the values which are encrypted already, and the cleartext ones when asked to, reporting whether
+// any value was encrypted
+func (impl *CredentialEncryptionServiceImpl) reEncryptAll(options ReEncryptOptions, values ...*string) (bool, error) {
+	var isEncrypted bool
+	for _, value := range values {
+		if len(*value) == 0 {
+			continue
+		}
+		credential := *value
+		scheme, reference, found := strings.Cut(credential, schemeSeparator)
+		if found && (scheme == SchemeFile || scheme == SchemeEnv || scheme == SchemeKubernetes) {
+			continue
+		}
+		if found && scheme == SchemeEnvelope {
+			var err error
+			credential, err = impl.envelopeSecretSource.GetSecret(reference)
+			if err != nil {
+				return false, err
+			}
+		} else if !options.EncryptCleartext {
+			continue
+		}
+		encrypted, err := impl.envelopeSecretSource.Encrypt(credential)
+		if err != nil {
+			return false, err
+		}
+		*value = encrypted
+		isEncrypted = true
+	}
+	return isEncrypted, nil
+}
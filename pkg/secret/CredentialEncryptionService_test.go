@@ -0,0 +1,174 @@
+package secret
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devtron-labs/chart-sync/internals/filestore"
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"go.uber.org/zap"
+)
+
+// testProviders are the credentials of the provider rows stored in the providers file of the file store
+type testProviders struct {
+	ChartRepos              []*sql.ChartRepo              `json:"chartRepos"`
+	DockerArtifactStores    []*sql.DockerArtifactStore    `json:"dockerArtifactStores"`
+	RemoteConnectionConfigs []*sql.RemoteConnectionConfig `json:"remoteConnectionConfigs"`
+}
+
+func writeTestProviders(t *testing.T, dir string, providers *testProviders) {
+	t.Helper()
+	content, err := json.Marshal(providers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "providers.json"), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestProviders(t *testing.T, dir string) *testProviders {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(dir, "providers.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	providers := &testProviders{}
+	if err = json.Unmarshal(content, providers); err != nil {
+		t.Fatal(err)
+	}
+	return providers
+}
+
+func newTestCredentialEncryptionService(t *testing.T, dir string, envelopeSecretSource *EnvelopeSecretSource) *CredentialEncryptionServiceImpl {
+	t.Helper()
+	fileStore, err := filestore.NewFileStore(zap.NewNop().Sugar(), dir)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	return NewCredentialEncryptionServiceImpl(zap.NewNop().Sugar(), envelopeSecretSource,
+		filestore.NewChartRepoRepositoryImpl(fileStore),
+		filestore.NewDockerArtifactStoreRepositoryImpl(fileStore),
+		filestore.NewRemoteConnectionRepositoryImpl(fileStore),
+		filestore.NewProviderRepositoryImpl(fileStore))
+}
+
+func TestCredentialEncryptionService_ReEncryptCredentials(t *testing.T) {
+	previousKeyFile, keyFile := newTestKeyFile(t), newTestKeyFile(t)
+	previousSource := newTestEnvelopeSecretSourceWithKeys(t, previousKeyFile, "")
+	rotatingSource := newTestEnvelopeSecretSourceWithKeys(t, keyFile, previousKeyFile)
+	currentSource := newTestEnvelopeSecretSourceWithKeys(t, keyFile, "")
+	encrypt := func(credential string) string {
+		return SchemeEnvelope + schemeSeparator + encryptReference(t, previousSource, credential)
+	}
+	newProviders := func() *testProviders {
+		return &testProviders{
+			ChartRepos: []*sql.ChartRepo{
+				{
+					Id: 1, Name: "encrypted", External: true, Active: true,
+					Password:      encrypt("password"),
+					CustomHeaders: `{"X-Api-Key":"` + encrypt("key") + `","X-Team":"charts"}`,
+				},
+				{Id: 2, Name: "cleartext", External: true, Active: true, AccessToken: "token"},
+				{Id: 3, Name: "referenced", External: true, Active: true, Password: "env://CHART_SYNC_PASSWORD"},
+			},
+			DockerArtifactStores: []*sql.DockerArtifactStore{
+				{Id: "registry", Active: true, Password: encrypt("registry-password"), RemoteConnectionConfigId: 1},
+			},
+			RemoteConnectionConfigs: []*sql.RemoteConnectionConfig{
+				{Id: 1, ConnectionMethod: "SSH", SSHPassword: encrypt("ssh-password")},
+			},
+		}
+	}
+	decrypt := func(t *testing.T, value string) string {
+		t.Helper()
+		reference, ok := strings.CutPrefix(value, SchemeEnvelope+schemeSeparator)
+		if !ok {
+			t.Fatalf("credential %q is not encrypted", value)
+		}
+		credential, err := currentSource.GetSecret(reference)
+		if err != nil {
+			t.Fatalf("GetSecret() error = %v, want the credential wrapped by the current key", err)
+		}
+		return credential
+	}
+
+	t.Run("rotates the key encryption key", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestProviders(t, dir, newProviders())
+		if err := newTestCredentialEncryptionService(t, dir, rotatingSource).ReEncryptCredentials(ReEncryptOptions{}); err != nil {
+			t.Fatalf("ReEncryptCredentials() error = %v", err)
+		}
+		providers := readTestProviders(t, dir)
+		if got := decrypt(t, providers.ChartRepos[0].Password); got != "password" {
+			t.Errorf("Password = %q, want password", got)
+		}
+		customHeaders := make(map[string]string)
+		if err := json.Unmarshal([]byte(providers.ChartRepos[0].CustomHeaders), &customHeaders); err != nil {
+			t.Fatalf("CustomHeaders = %q, want a json object", providers.ChartRepos[0].CustomHeaders)
+		}
+		if got := decrypt(t, customHeaders["X-Api-Key"]); got != "key" {
+			t.Errorf("CustomHeaders[X-Api-Key] = %q, want key", got)
+		}
+		if customHeaders["X-Team"] != "charts" {
+			t.Errorf("CustomHeaders[X-Team] = %q, want the cleartext header left as it is", customHeaders["X-Team"])
+		}
+		if providers.ChartRepos[1].AccessToken != "token" {
+			t.Errorf("AccessToken = %q, want the cleartext credential left as it is", providers.ChartRepos[1].AccessToken)
+		}
+		if providers.ChartRepos[2].Password != "env://CHART_SYNC_PASSWORD" {
+			t.Errorf("Password = %q, want the reference left as it is", providers.ChartRepos[2].Password)
+		}
+		if got := decrypt(t, providers.DockerArtifactStores[0].Password); got != "registry-password" {
+			t.Errorf("Password = %q, want registry-password", got)
+		}
+		if got := decrypt(t, providers.RemoteConnectionConfigs[0].SSHPassword); got != "ssh-password" {
+			t.Errorf("SSHPassword = %q, want ssh-password", got)
+		}
+	})
+
+	t.Run("encrypts cleartext credentials when asked to", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestProviders(t, dir, newProviders())
+		if err := newTestCredentialEncryptionService(t, dir, rotatingSource).ReEncryptCredentials(ReEncryptOptions{EncryptCleartext: true}); err != nil {
+			t.Fatalf("ReEncryptCredentials() error = %v", err)
+		}
+		providers := readTestProviders(t, dir)
+		if got := decrypt(t, providers.ChartRepos[1].AccessToken); got != "token" {
+			t.Errorf("AccessToken = %q, want token", got)
+		}
+		if providers.ChartRepos[2].Password != "env://CHART_SYNC_PASSWORD" {
+			t.Errorf("Password = %q, want the reference left as it is", providers.ChartRepos[2].Password)
+		}
+	})
+
+	t.Run("dry run updates no row", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestProviders(t, dir, newProviders())
+		before, _ := os.ReadFile(filepath.Join(dir, "providers.json"))
+		if err := newTestCredentialEncryptionService(t, dir, rotatingSource).ReEncryptCredentials(ReEncryptOptions{DryRun: true, EncryptCleartext: true}); err != nil {
+			t.Fatalf("ReEncryptCredentials() error = %v", err)
+		}
+		if after, _ := os.ReadFile(filepath.Join(dir, "providers.json")); string(after) != string(before) {
+			t.Error("ReEncryptCredentials() changed the providers file on a dry run")
+		}
+	})
+
+	t.Run("updates no row when any row fails", func(t *testing.T) {
+		dir := t.TempDir()
+		providers := newProviders()
+		// wrapped by a key which is neither the current nor the previous one
+		providers.RemoteConnectionConfigs[0].SSHAuthKey = SchemeEnvelope + schemeSeparator + encryptReference(t, newTestEnvelopeSecretSource(t), "ssh-key")
+		writeTestProviders(t, dir, providers)
+		before, _ := os.ReadFile(filepath.Join(dir, "providers.json"))
+		if err := newTestCredentialEncryptionService(t, dir, rotatingSource).ReEncryptCredentials(ReEncryptOptions{}); err == nil {
+			t.Fatal("ReEncryptCredentials() expected error for a credential wrapped by an unknown key")
+		}
+		if after, _ := os.ReadFile(filepath.Join(dir, "providers.json")); string(after) != string(before) {
+			t.Error("ReEncryptCredentials() changed the providers file although a row failed")
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package secret
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/devtron-labs/chart-sync/internals"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	SchemeEnvelope  = "enc"
+	envelopeVersion = "v1"
+	dataKeySize     = 32
+)
+
+// KeyEncryptionKey wraps and unwraps the data keys credentials are encrypted with
+type KeyEncryptionKey interface {
+	WrapKey(dataKey []byte) ([]byte, error)
+	UnwrapKey(wrappedKey []byte) ([]byte, error)
+}
+
+// FileKeyEncryptionKey wraps data keys with AES-GCM using a 32 byte key read from a local file, encoded in base64
+type FileKeyEncryptionKey struct {
+	aead cipher.AEAD
+}
+
+func NewFileKeyEncryptionKey(keyFile string) (*FileKeyEncryptionKey, error) {
+	content, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key encryption key in %s: %w", keyFile, err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key encryption key in %s: %w", keyFile, err)
+	}
+	return &FileKeyEncryptionKey{aead: aead}, nil
+}
+
+func (impl *FileKeyEncryptionKey) WrapKey(dataKey []byte) ([]byte, error) {
+	return seal(impl.aead, dataKey)
+}
+
+func (impl *FileKeyEncryptionKey) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	return open(impl.aead, wrappedKey)
+}
+
+// PluginKeyEncryptionKey wraps data keys through an executable, like a client of a KMS, called with wrap or unwrap as
+// argument. The plugin reads the base64 encoded key from stdin and writes the base64 encoded result to stdout.
+type PluginKeyEncryptionKey struct {
+	command string
+}
+
+func NewPluginKeyEncryptionKey(command string) *PluginKeyEncryptionKey {
+	return &PluginKeyEncryptionKey{command: command}
+}
+
+func (impl *PluginKeyEncryptionKey) WrapKey(dataKey []byte) ([]byte, error) {
+	return impl.run("wrap", dataKey)
+}
+
+func (impl *PluginKeyEncryptionKey) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	return impl.run("unwrap", wrappedKey)
+}
+
+func (impl *PluginKeyEncryptionKey) run(operation string, key []byte) ([]byte, error) {
+	cmd := exec.Command(impl.command, operation)
+	cmd.Stdin = strings.NewReader(base64.StdEncoding.EncodeToString(key))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("key encryption key plugin failed to %s key: %w: %s", operation, err, strings.TrimSpace(stderr.String()))
+	}
+	result, err := base64.StdEncoding.DecodeString(strings.TrimSpace(stdout.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid output of key encryption key plugin: %w", err)
+	}
+	return result, nil
+}
+
+// EnvelopeSecretSource decrypts credentials stored as enc://v1.<wrapped data key>.<encrypted credential>. Every
+// credential is encrypted with AES-GCM using its own data key, which is wrapped by the key encryption key.
+// While the key encryption key is rotated, data keys the current key does not unwrap are unwrapped by the previous
+// one, which never wraps new data keys.
+type EnvelopeSecretSource struct {
+	keyEncryptionKey         KeyEncryptionKey
+	previousKeyEncryptionKey KeyEncryptionKey
+	err                      error // why there is no key encryption key, returned for every encrypted credential
+}
+
+// NewEnvelopeSecretSource loads the key encryption key from the plugin or the key file of the configuration, and the
+// previous key encryption key from its key file. Without a key encryption key, credentials are expected in cleartext
+// and encrypted ones fail to resolve.
+func NewEnvelopeSecretSource(logger *zap.SugaredLogger, configuration *internals.Configuration) *EnvelopeSecretSource {
+	impl := &EnvelopeSecretSource{}
+	switch {
+	case len(configuration.CredentialKeyEncryptionKeyPlugin) > 0:
+		impl.keyEncryptionKey = NewPluginKeyEncryptionKey(configuration.CredentialKeyEncryptionKeyPlugin)
+	case len(configuration.CredentialKeyEncryptionKeyFile) > 0:
+		impl.keyEncryptionKey, impl.err = NewFileKeyEncryptionKey(configuration.CredentialKeyEncryptionKeyFile)
+		if impl.err != nil {
+			logger.Errorw("error in loading key encryption key of credentials", "file", configuration.CredentialKeyEncryptionKeyFile, "err", impl.err)
+		}
+	default:
+		impl.err = errors.New("no key encryption key configured for encrypted credentials")
+	}
+	if impl.err == nil && len(configuration.CredentialPreviousKeyEncryptionKeyFile) > 0 {
+		impl.previousKeyEncryptionKey, impl.err = NewFileKeyEncryptionKey(configuration.CredentialPreviousKeyEncryptionKeyFile)
+		if impl.err != nil {
+			logger.Errorw("error in loading previous key encryption key of credentials", "file", configuration.CredentialPreviousKeyEncryptionKeyFile, "err", impl.err)
+		} else {
+			logger.Infow("unwrapping data keys with the previous key encryption key as well while it is rotated")
+		}
+	}
+	return impl
+}
+
+func (impl *EnvelopeSecretSource) GetSecret(reference string) (string, error) {
+	if impl.err != nil {
+		return "", impl.err
+	}
+	segments := strings.Split(reference, ".")
+	if len(segments) != 3 || segments[0] != envelopeVersion {
+		return "", errors.New("encrypted credential must be " + envelopeVersion + ".<wrapped data key>.<ciphertext>")
+	}
+	wrappedKey, err := base64.RawURLEncoding.DecodeString(segments[1])
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(segments[2])
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := impl.keyEncryptionKey.UnwrapKey(wrappedKey)
+	if err != nil && impl.previousKeyEncryptionKey != nil {
+		dataKey, err = impl.previousKeyEncryptionKey.UnwrapKey(wrappedKey)
+	}
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(aead, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Encrypt encrypts the credential with a new data key, returning the value to store in place of the credential
+func (impl *EnvelopeSecretSource) Encrypt(credential string) (string, error) {
+	if impl.err != nil {
+		return "", impl.err
+	}
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(aead, []byte(credential))
+	if err != nil {
+		return "", err
+	}
+	wrappedKey, err := impl.keyEncryptionKey.WrapKey(dataKey)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%s%s.%s.%s", SchemeEnvelope, schemeSeparator, envelopeVersion,
+		base64.RawURLEncoding.EncodeToString(wrappedKey), base64.RawURLEncoding.EncodeToString(ciphertext)), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("key must be %d bytes", dataKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext prefixing the ciphertext with a random nonce
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], nil)
+}
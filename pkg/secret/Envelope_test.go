@@ -0,0 +1,154 @@
+package secret
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devtron-labs/chart-sync/internals"
+	"go.uber.org/zap"
+)
+
+// newTestKeyFile writes a new key encryption key file and returns its path
+func newTestKeyFile(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "kek")
+	if err := os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return keyFile
+}
+
+func newTestEnvelopeSecretSource(t *testing.T) *EnvelopeSecretSource {
+	t.Helper()
+	return newTestEnvelopeSecretSourceWithKeys(t, newTestKeyFile(t), "")
+}
+
+func newTestEnvelopeSecretSourceWithKeys(t *testing.T, keyFile, previousKeyFile string) *EnvelopeSecretSource {
+	t.Helper()
+	envelopeSecretSource := NewEnvelopeSecretSource(zap.NewNop().Sugar(), &internals.Configuration{
+		CredentialKeyEncryptionKeyFile:         keyFile,
+		CredentialPreviousKeyEncryptionKeyFile: previousKeyFile,
+	})
+	if envelopeSecretSource.err != nil {
+		t.Fatalf("NewEnvelopeSecretSource() error = %v", envelopeSecretSource.err)
+	}
+	return envelopeSecretSource
+}
+
+// encryptReference encrypts the credential and returns the reference after enc://
+func encryptReference(t *testing.T, envelopeSecretSource *EnvelopeSecretSource, credential string) string {
+	t.Helper()
+	encrypted, err := envelopeSecretSource.Encrypt(credential)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	prefix := SchemeEnvelope + schemeSeparator + envelopeVersion + "."
+	if !strings.HasPrefix(encrypted, prefix) {
+		t.Fatalf("Encrypt() = %s, want prefix %s", encrypted, prefix)
+	}
+	return strings.TrimPrefix(encrypted, SchemeEnvelope+schemeSeparator)
+}
+
+func TestEnvelopeSecretSource_RoundTrip(t *testing.T) {
+	envelopeSecretSource := newTestEnvelopeSecretSource(t)
+	for _, credential := range []string{"", "password", "päss wörd:with.dots", strings.Repeat("k", 4096)} {
+		reference := encryptReference(t, envelopeSecretSource, credential)
+		got, err := envelopeSecretSource.GetSecret(reference)
+		if err != nil {
+			t.Fatalf("GetSecret() error = %v", err)
+		}
+		if got != credential {
+			t.Errorf("GetSecret() = %q, want %q", got, credential)
+		}
+		if reference == encryptReference(t, envelopeSecretSource, credential) {
+			t.Errorf("Encrypt() returned the same value twice for %q", credential)
+		}
+	}
+}
+
+func TestEnvelopeSecretSource_GetSecretErrors(t *testing.T) {
+	envelopeSecretSource := newTestEnvelopeSecretSource(t)
+	reference := encryptReference(t, envelopeSecretSource, "password")
+	segments := strings.Split(reference, ".")
+	ciphertext, _ := base64.RawURLEncoding.DecodeString(segments[2])
+	tamperedCiphertext := append([]byte{}, ciphertext...)
+	tamperedCiphertext[len(tamperedCiphertext)-1] ^= 1
+	wrappedKey, _ := base64.RawURLEncoding.DecodeString(segments[1])
+	tamperedWrappedKey := append([]byte{}, wrappedKey...)
+	tamperedWrappedKey[0] ^= 1
+	tests := []struct {
+		name                 string
+		envelopeSecretSource *EnvelopeSecretSource
+		reference            string
+	}{
+		{
+			name:                 "wrong key encryption key",
+			envelopeSecretSource: newTestEnvelopeSecretSource(t),
+			reference:            reference,
+		},
+		{
+			name:                 "no key encryption key",
+			envelopeSecretSource: NewEnvelopeSecretSource(zap.NewNop().Sugar(), &internals.Configuration{}),
+			reference:            reference,
+		},
+		{
+			name:      "unknown version",
+			reference: "v2." + segments[1] + "." + segments[2],
+		},
+		{
+			name:      "missing ciphertext",
+			reference: segments[0] + "." + segments[1],
+		},
+		{
+			name:      "truncated ciphertext",
+			reference: segments[0] + "." + segments[1] + "." + base64.RawURLEncoding.EncodeToString(ciphertext[:8]),
+		},
+		{
+			name:      "tampered ciphertext",
+			reference: segments[0] + "." + segments[1] + "." + base64.RawURLEncoding.EncodeToString(tamperedCiphertext),
+		},
+		{
+			name:      "tampered wrapped key",
+			reference: segments[0] + "." + base64.RawURLEncoding.EncodeToString(tamperedWrappedKey) + "." + segments[2],
+		},
+		{
+			name:      "invalid base64",
+			reference: segments[0] + ".!!." + segments[2],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := tt.envelopeSecretSource
+			if source == nil {
+				source = envelopeSecretSource
+			}
+			if got, err := source.GetSecret(tt.reference); err == nil {
+				t.Errorf("GetSecret() = %q, want error", got)
+			}
+		})
+	}
+}
+
+func TestEnvelopeSecretSource_PreviousKeyEncryptionKey(t *testing.T) {
+	previousKeyFile, keyFile := newTestKeyFile(t), newTestKeyFile(t)
+	previousReference := encryptReference(t, newTestEnvelopeSecretSourceWithKeys(t, previousKeyFile, ""), "password")
+	rotatingSource := newTestEnvelopeSecretSourceWithKeys(t, keyFile, previousKeyFile)
+	if got, err := rotatingSource.GetSecret(previousReference); err != nil || got != "password" {
+		t.Errorf("GetSecret() = %q, %v, want the credential encrypted with the previous key", got, err)
+	}
+	reference := encryptReference(t, rotatingSource, "password")
+	if got, err := newTestEnvelopeSecretSourceWithKeys(t, keyFile, "").GetSecret(reference); err != nil || got != "password" {
+		t.Errorf("GetSecret() = %q, %v, want the credential encrypted with the current key", got, err)
+	}
+	if got, err := newTestEnvelopeSecretSourceWithKeys(t, previousKeyFile, "").GetSecret(reference); err == nil {
+		t.Errorf("GetSecret() = %q, want error as the previous key never wraps new data keys", got)
+	}
+}
@@ -0,0 +1,179 @@
+package secret
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/devtron-labs/chart-sync/internals"
+	logger2 "github.com/devtron-labs/chart-sync/internals/logger"
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+)
+
+const (
+	SchemeFile       = "file"
+	SchemeEnv        = "env"
+	SchemeKubernetes = "k8s"
+	schemeSeparator  = "://"
+)
+
+// SecretSource returns the secret a reference points to, the reference being the part of a credential value after the
+// scheme and ://
+type SecretSource interface {
+	GetSecret(reference string) (string, error)
+}
+
+// SecretResolver resolves credential values of chart providers which reference an external secret, like
+// file:///etc/secrets/harbor/password, env://HARBOR_PASSWORD or k8s://devtroncd/harbor-credentials/password, and
+// credentials encrypted with a data key like enc://v1.<wrapped data key>.<ciphertext>. Values without the scheme of a
+// registered source are plaintext credentials and are returned as they are.
+// As provider rows are edited by users of the orchestrator, file, env and k8s references only resolve to the directories,
+// environment variable prefix and namespaces allowed in the configuration, which allows none by default.
+type SecretResolver interface {
+	Resolve(value string) (string, error)
+	// ResolveChartRepo replaces the secret references among the credentials of the chart repo, including the values of
+	// its custom headers, with their secrets
+	ResolveChartRepo(chartRepo *sql.ChartRepo) error
+	// ResolveDockerArtifactStore replaces the secret references among the credentials of the store with their secrets
+	ResolveDockerArtifactStore(store *sql.DockerArtifactStore) error
+	// RegisterSource makes references with the scheme resolve through the source, replacing any source of the scheme
+	RegisterSource(scheme string, source SecretSource)
+}
+
+type SecretResolverImpl struct {
+	logger  *zap.SugaredLogger
+	sources map[string]SecretSource
+	mutex   sync.RWMutex
+}
+
+func NewSecretResolverImpl(logger *zap.SugaredLogger, envelopeSecretSource *EnvelopeSecretSource, configuration *internals.Configuration) *SecretResolverImpl {
+	return &SecretResolverImpl{
+		logger: logger.Named(logger2.ComponentSync),
+		sources: map[string]SecretSource{
+			SchemeFile:       NewFileSecretSource(splitList(configuration.SecretReferenceFileDirs)),
+			SchemeEnv:        NewEnvSecretSource(configuration.SecretReferenceEnvPrefix),
+			SchemeKubernetes: NewKubernetesSecretSource(splitList(configuration.SecretReferenceK8sNamespaces)),
+			SchemeEnvelope:   envelopeSecretSource,
+		},
+	}
+}
+
+// IsSecretReference reports whether the value references a secret of one of the built-in sources, including enc://
+// credentials, instead of being a plaintext credential
+func IsSecretReference(value string) bool {
+	scheme, _, found := strings.Cut(value, schemeSeparator)
+	return found && (scheme == SchemeFile || scheme == SchemeEnv || scheme == SchemeKubernetes || scheme == SchemeEnvelope)
+}
+
+// splitList returns the non empty items of a comma separated list
+func splitList(list string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (impl *SecretResolverImpl) RegisterSource(scheme string, source SecretSource) {
+	impl.mutex.Lock()
+	defer impl.mutex.Unlock()
+	impl.sources[scheme] = source
+}
+
+func (impl *SecretResolverImpl) Resolve(value string) (string, error) {
+	scheme, reference, found := strings.Cut(value, schemeSeparator)
+	if !found {
+		return value, nil
+	}
+	impl.mutex.RLock()
+	source, ok := impl.sources[scheme]
+	impl.mutex.RUnlock()
+	if !ok {
+		return value, nil
+	}
+	secret, err := source.GetSecret(reference)
+	if err != nil {
+		// the reference is left out of the error as it can be a mistyped plaintext credential
+		return "", fmt.Errorf("failed to resolve %s secret reference: %w", scheme, err)
+	}
+	return secret, nil
+}
+
+func (impl *SecretResolverImpl) ResolveChartRepo(chartRepo *sql.ChartRepo) error {
+	err := impl.resolveAll(&chartRepo.Password, &chartRepo.AccessToken)
+	if err == nil {
+		err = impl.resolveCustomHeaders(&chartRepo.CustomHeaders)
+	}
+	if err != nil {
+		impl.logger.Errorw("error in resolving credentials of chart repo", "repo", chartRepo.Name, "err", err)
+		return err
+	}
+	err = impl.resolveRemoteConnectionConfig(chartRepo.RemoteConnectionConfig)
+	if err != nil {
+		impl.logger.Errorw("error in resolving credentials of remote connection of chart repo", "repo", chartRepo.Name, "err", err)
+		return err
+	}
+	return nil
+}
+
+func (impl *SecretResolverImpl) ResolveDockerArtifactStore(store *sql.DockerArtifactStore) error {
+	err := impl.resolveAll(&store.Password, &store.AWSSecretAccessKey, &store.ClientKey)
+	if err != nil {
+		impl.logger.Errorw("error in resolving credentials of registry", "registryName", store.Id, "err", err)
+		return err
+	}
+	err = impl.resolveRemoteConnectionConfig(store.RemoteConnectionConfig)
+	if err != nil {
+		impl.logger.Errorw("error in resolving credentials of remote connection of registry", "registryName", store.Id, "err", err)
+		return err
+	}
+	return nil
+}
+
+func (impl *SecretResolverImpl) resolveRemoteConnectionConfig(remoteConnectionConfig *sql.RemoteConnectionConfig) error {
+	if remoteConnectionConfig == nil {
+		return nil
+	}
+	return impl.resolveAll(&remoteConnectionConfig.SSHPassword, &remoteConnectionConfig.SSHAuthKey)
+}
+
+// resolveCustomHeaders replaces the secret references among the values of the json object of custom headers
+func (impl *SecretResolverImpl) resolveCustomHeaders(customHeaders *string) error {
+	if len(*customHeaders) == 0 {
+		return nil
+	}
+	headers := make(map[string]string)
+	err := json.Unmarshal([]byte(*customHeaders), &headers)
+	if err != nil {
+		// the value is left out of the error as it holds the credentials
+		return errors.New("custom headers are not a json object of header name to header value")
+	}
+	for name, value := range headers {
+		secret, err := impl.Resolve(value)
+		if err != nil {
+			return fmt.Errorf("header %s: %w", name, err)
+		}
+		headers[name] = secret
+	}
+	resolvedHeaders, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+	*customHeaders = string(resolvedHeaders)
+	return nil
+}
+
+func (impl *SecretResolverImpl) resolveAll(values ...*string) error {
+	for _, value := range values {
+		secret, err := impl.Resolve(*value)
+		if err != nil {
+			return err
+		}
+		*value = secret
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package secret
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/devtron-labs/chart-sync/internals"
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"go.uber.org/zap"
+)
+
+func TestSecretResolver_ResolveChartRepo(t *testing.T) {
+	t.Setenv("CHART_SYNC_TEST_API_KEY", "key")
+	t.Setenv("CHART_SYNC_TEST_PASSWORD", "password")
+	envelopeSecretSource := newTestEnvelopeSecretSource(t)
+	encryptedToken, err := envelopeSecretSource.Encrypt("token")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	secretResolver := NewSecretResolverImpl(zap.NewNop().Sugar(), envelopeSecretSource, &internals.Configuration{SecretReferenceEnvPrefix: "CHART_SYNC_TEST_"})
+	tests := []struct {
+		name              string
+		chartRepo         sql.ChartRepo
+		wantPassword      string
+		wantCustomHeaders map[string]string
+		wantErr           bool
+	}{
+		{
+			name:         "plaintext credentials",
+			chartRepo:    sql.ChartRepo{Password: "password", CustomHeaders: `{"X-Api-Key":"key"}`},
+			wantPassword: "password",
+			wantCustomHeaders: map[string]string{
+				"X-Api-Key": "key",
+			},
+		},
+		{
+			name:         "references among password and custom headers",
+			chartRepo:    sql.ChartRepo{Password: "env://CHART_SYNC_TEST_PASSWORD", CustomHeaders: `{"X-Api-Key":"env://CHART_SYNC_TEST_API_KEY","Authorization":"` + encryptedToken + `","X-Team":"charts"}`},
+			wantPassword: "password",
+			wantCustomHeaders: map[string]string{
+				"X-Api-Key":     "key",
+				"Authorization": "token",
+				"X-Team":        "charts",
+			},
+		},
+		{
+			name:      "custom header referencing a variable without the allowed prefix",
+			chartRepo: sql.ChartRepo{CustomHeaders: `{"X-Api-Key":"env://HOME"}`},
+			wantErr:   true,
+		},
+		{
+			name:      "custom headers which are not a json object",
+			chartRepo: sql.ChartRepo{CustomHeaders: `X-Api-Key: key`},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chartRepo := tt.chartRepo
+			err := secretResolver.ResolveChartRepo(&chartRepo)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveChartRepo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if chartRepo.Password != tt.wantPassword {
+				t.Errorf("Password = %q, want %q", chartRepo.Password, tt.wantPassword)
+			}
+			customHeaders := make(map[string]string)
+			if err = json.Unmarshal([]byte(chartRepo.CustomHeaders), &customHeaders); err != nil {
+				t.Fatalf("CustomHeaders = %q, want a json object", chartRepo.CustomHeaders)
+			}
+			if len(customHeaders) != len(tt.wantCustomHeaders) {
+				t.Errorf("CustomHeaders = %v, want %v", customHeaders, tt.wantCustomHeaders)
+			}
+			for name, value := range tt.wantCustomHeaders {
+				if customHeaders[name] != value {
+					t.Errorf("CustomHeaders[%s] = %q, want %q", name, customHeaders[name], value)
+				}
+			}
+		})
+	}
+}
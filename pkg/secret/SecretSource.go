@@ -0,0 +1,160 @@
+package secret
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/exp/slices"
+	"io"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// FileSecretSource reads secrets from files, like the ones of mounted Kubernetes Secrets. The reference is the
+// absolute path of the file, trailing line breaks are trimmed. Only files in the allowed directories are read, after
+// resolving symlinks.
+type FileSecretSource struct {
+	allowedDirs []string
+}
+
+func NewFileSecretSource(allowedDirs []string) *FileSecretSource {
+	return &FileSecretSource{allowedDirs: allowedDirs}
+}
+
+func (impl *FileSecretSource) GetSecret(reference string) (string, error) {
+	if !filepath.IsAbs(reference) {
+		return "", errors.New("file secret reference must be an absolute path")
+	}
+	notAllowedErr := errors.New("file secret reference is not in a directory of SECRET_REFERENCE_FILE_DIRS")
+	// checked before resolving symlinks too, so that files outside of the directories are not even looked up
+	if !impl.isAllowed(filepath.Clean(reference)) {
+		return "", notAllowedErr
+	}
+	path, err := filepath.EvalSymlinks(reference)
+	if err != nil {
+		return "", err
+	}
+	if !impl.isAllowed(path) {
+		return "", notAllowedErr
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+func (impl *FileSecretSource) isAllowed(path string) bool {
+	for _, allowedDir := range impl.allowedDirs {
+		if resolvedDir, err := filepath.EvalSymlinks(allowedDir); err == nil {
+			allowedDir = resolvedDir
+		}
+		if relativePath, err := filepath.Rel(allowedDir, path); err == nil && relativePath != ".." &&
+			!strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnvSecretSource reads secrets from environment variables, the reference is the name of the variable. Only variables
+// with the allowed prefix are read, none when it is empty.
+type EnvSecretSource struct {
+	allowedPrefix string
+}
+
+func NewEnvSecretSource(allowedPrefix string) *EnvSecretSource {
+	return &EnvSecretSource{allowedPrefix: allowedPrefix}
+}
+
+func (impl *EnvSecretSource) GetSecret(reference string) (string, error) {
+	if len(impl.allowedPrefix) == 0 || !strings.HasPrefix(reference, impl.allowedPrefix) {
+		return "", errors.New("env secret reference does not have the prefix of SECRET_REFERENCE_ENV_PREFIX")
+	}
+	secret, ok := os.LookupEnv(reference)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", reference)
+	}
+	return secret, nil
+}
+
+// KubernetesSecretSource reads keys of Kubernetes Secrets through the API server of the cluster chart-sync runs in.
+// The reference is namespace/name/key, or name/key for Secrets in the namespace of chart-sync. Only Secrets in the
+// allowed namespaces are read, so the namespace of chart-sync has to be allowed as well for name/key references.
+type KubernetesSecretSource struct {
+	allowedNamespaces []string
+	once              sync.Once
+	initErr           error
+	host              string
+	namespace         string
+	httpClient        *http.Client
+}
+
+func NewKubernetesSecretSource(allowedNamespaces []string) *KubernetesSecretSource {
+	return &KubernetesSecretSource{allowedNamespaces: allowedNamespaces}
+}
+
+func (impl *KubernetesSecretSource) GetSecret(reference string) (string, error) {
+	// the in cluster config is only looked up once a k8s reference is used, so that chart-sync keeps running outside
+	// of clusters as long as no provider references a Kubernetes Secret
+	impl.once.Do(impl.init)
+	if impl.initErr != nil {
+		return "", impl.initErr
+	}
+	segments := strings.Split(reference, "/")
+	namespace := impl.namespace
+	if len(segments) == 3 {
+		namespace, segments = segments[0], segments[1:]
+	}
+	if len(segments) != 2 || len(namespace) == 0 || len(segments[0]) == 0 || len(segments[1]) == 0 {
+		return "", errors.New("kubernetes secret reference must be [namespace/]name/key")
+	}
+	if !slices.Contains(impl.allowedNamespaces, namespace) {
+		return "", errors.New("kubernetes secret reference is not in a namespace of SECRET_REFERENCE_K8S_NAMESPACES")
+	}
+	name, key := segments[0], segments[1]
+	resp, err := impl.httpClient.Get(fmt.Sprintf("%s/api/v1/namespaces/%s/secrets/%s", impl.host, url.PathEscape(namespace), url.PathEscape(name)))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get secret %s/%s: unexpected status code %d", namespace, name, resp.StatusCode)
+	}
+	var secret struct {
+		Data map[string][]byte `json:"data"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(&secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode secret %s/%s: %w", namespace, name, err)
+	}
+	value, ok := secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s/%s has no key %s", namespace, name, key)
+	}
+	return string(value), nil
+}
+
+func (impl *KubernetesSecretSource) init() {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		impl.initErr = err
+		return
+	}
+	impl.httpClient, err = rest.HTTPClientFor(config)
+	if err != nil {
+		impl.initErr = err
+		return
+	}
+	impl.host = strings.TrimSuffix(config.Host, "/")
+	if namespace, err := os.ReadFile(serviceAccountNamespaceFile); err == nil {
+		impl.namespace = strings.TrimSpace(string(namespace))
+	}
+}
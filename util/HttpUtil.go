@@ -19,73 +19,169 @@ package util
 
 import (
 	"bytes"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"github.com/devtron-labs/chart-sync/internals/sql"
+	http2 "github.com/devtron-labs/common-lib/utils/http"
 	"github.com/pkg/errors"
-	"helm.sh/helm/v3/pkg/cli"
+	"golang.org/x/crypto/ssh"
 	"helm.sh/helm/v3/pkg/getter"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
 	"time"
 )
 
-func GetFromPublicUrlWithRetry(url string) (*bytes.Buffer, error) {
-	var (
-		err      error
-		response *http.Response
-		retries  = 3
-	)
+// ChartRepoGetter is a helm getter.Getter for http(s) chart repositories. It applies the
+// authentication mode of the chart repo to every request, so index and chart archive
+// downloads are authenticated the same way. Credentials are only sent to the host of the
+// chart repo url unless PassCredentialsAll is set on the chart repo. Requests go through the proxy or ssh tunnel
+// of the chart repo's remote connection config, Close must be called once done.
+type ChartRepoGetter struct {
+	chartRepo     *sql.ChartRepo
+	repoUrl       *url.URL
+	authMode      sql.AuthMode
+	customHeaders map[string]string
+	client        *http.Client
+	transport     *RemoteConnectionTransport
+}
 
-	for retries > 0 {
-		response, err = http.Get(url)
-		if err != nil {
-			retries -= 1
-			time.Sleep(1 * time.Second)
-		} else {
-			break
-		}
+func NewChartRepoGetter(chartRepo *sql.ChartRepo, sshHostKeyCallback ssh.HostKeyCallback) (*ChartRepoGetter, error) {
+	repoUrl, err := url.Parse(chartRepo.Url)
+	if err != nil {
+		return nil, errors.Errorf("invalid chart URL format: %s", chartRepo.Url)
 	}
-	if response != nil {
-		defer response.Body.Close()
-		statusCode := response.StatusCode
-		if statusCode != http.StatusOK {
-			return nil, errors.New(fmt.Sprintf("Error in getting content from url - %s. Status code : %s", url, strconv.Itoa(statusCode)))
-		}
-		body, err := ioutil.ReadAll(response.Body)
+	chartRepoGetter := &ChartRepoGetter{
+		chartRepo: chartRepo,
+		repoUrl:   repoUrl,
+		authMode:  GetChartRepoAuthMode(chartRepo),
+	}
+	if chartRepoGetter.authMode == sql.AUTH_MODE_CUSTOM_HEADERS {
+		err = json.Unmarshal([]byte(chartRepo.CustomHeaders), &chartRepoGetter.customHeaders)
 		if err != nil {
-			return nil, err
+			return nil, errors.Wrapf(err, "invalid custom headers for chart repo %s", chartRepo.Name)
 		}
-		return bytes.NewBuffer(body), nil
 	}
-	return nil, err
+	tlsConfig, err := http2.NewClientTLS(chartRepo.CertFile, chartRepo.KeyFile, chartRepo.CAFile, chartRepo.AllowInsecureConnection)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't create TLS config for client")
+	}
+	chartRepoGetter.transport, err = NewRemoteConnectionTransport(chartRepo.RemoteConnectionConfig, tlsConfig, sshHostKeyCallback)
+	if err != nil {
+		return nil, err
+	}
+	chartRepoGetter.client = &http.Client{
+		Transport:     chartRepoGetter.transport,
+		CheckRedirect: chartRepoGetter.checkRedirect,
+	}
+	return chartRepoGetter, nil
 }
 
-func GetFromPrivateUrlWithRetry(baseurl string, absoluteUrl string, username string, password string, allowInsecureConnection bool) (*bytes.Buffer, error) {
-	var (
-		err, errInGetUrl error
-		response         *bytes.Buffer
-		retries          = 3
-	)
-	getters := getter.All(&cli.EnvSettings{})
-	u, err := url.Parse(baseurl)
-	if err != nil {
-		return nil, errors.Errorf("invalid chart URL format: %s", baseurl)
+// NewHttpClient returns a client for other hosts than the chart repo, like registries of oci:// chart urls of its index,
+// which goes through the proxy or ssh tunnel of the chart repo with the given tls.Config. It can only be used until the
+// getter is closed.
+func (impl *ChartRepoGetter) NewHttpClient(tlsConfig *tls.Config) *http.Client {
+	return &http.Client{Transport: impl.transport.WithTLSConfig(tlsConfig)}
+}
+
+func (impl *ChartRepoGetter) Close() error {
+	return impl.transport.Close()
+}
+
+// GetChartRepoAuthMode returns the auth mode of the chart repo. Chart repos saved before auth modes
+// were introduced have no auth mode, these use basic auth when both username and password are set.
+func GetChartRepoAuthMode(chartRepo *sql.ChartRepo) sql.AuthMode {
+	if len(chartRepo.AuthMode) > 0 {
+		return chartRepo.AuthMode
 	}
-	client, err := getters.ByScheme(u.Scheme)
+	if len(chartRepo.Username) > 0 && len(chartRepo.Password) > 0 {
+		return sql.AUTH_MODE_USERNAME_PASSWORD
+	}
+	return sql.AUTH_MODE_ANONYMOUS
+}
 
+// Providers returns getter providers serving http and https with this getter, to be used with repo.NewChartRepository
+func (impl *ChartRepoGetter) Providers() getter.Providers {
+	return getter.Providers{
+		{
+			Schemes: []string{"http", "https"},
+			New: func(options ...getter.Option) (getter.Getter, error) {
+				return impl, nil
+			},
+		},
+	}
+}
+
+// Get downloads the given url. Getter options are ignored as everything is derived from the chart repo.
+func (impl *ChartRepoGetter) Get(href string, options ...getter.Option) (*bytes.Buffer, error) {
+	req, err := http.NewRequest(http.MethodGet, href, nil)
+	if err != nil {
+		return nil, err
+	}
+	impl.setCredentials(req)
+	response, err := impl.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New(fmt.Sprintf("Error in getting content from url - %s. Status code : %s", href, strconv.Itoa(response.StatusCode)))
+	}
+	body, err := io.ReadAll(response.Body)
 	if err != nil {
-		return nil, errors.Errorf("could not find protocol handler for: %s", u.Scheme)
+		return nil, err
 	}
+	return bytes.NewBuffer(body), nil
+}
 
-	for retries > 0 {
-		response, errInGetUrl = client.Get(absoluteUrl,
-			getter.WithURL(baseurl),
-			getter.WithInsecureSkipVerifyTLS(allowInsecureConnection),
-			getter.WithBasicAuth(username, password),
-		)
+func (impl *ChartRepoGetter) setCredentials(req *http.Request) {
+	if !impl.canPassCredentials(req.URL) {
+		return
+	}
+	switch impl.authMode {
+	case sql.AUTH_MODE_USERNAME_PASSWORD:
+		req.SetBasicAuth(impl.chartRepo.Username, impl.chartRepo.Password)
+	case sql.AUTH_MODE_ACCESS_TOKEN:
+		req.Header.Set("Authorization", "Bearer "+impl.chartRepo.AccessToken)
+	case sql.AUTH_MODE_CUSTOM_HEADERS:
+		for name, value := range impl.customHeaders {
+			req.Header.Set(name, value)
+		}
+	}
+}
 
+// checkRedirect drops the credentials copied over from the previous request when a redirect leaves the chart repo host,
+// and sets them again otherwise, as the http client drops the Authorization header on redirects to other domains
+func (impl *ChartRepoGetter) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if impl.canPassCredentials(req.URL) {
+		impl.setCredentials(req)
+		return nil
+	}
+	req.Header.Del("Authorization")
+	for name := range impl.customHeaders {
+		req.Header.Del(name)
+	}
+	return nil
+}
+
+// canPassCredentials checks that the url is served by the chart repo, including the port, before credentials are attached
+func (impl *ChartRepoGetter) canPassCredentials(u *url.URL) bool {
+	return impl.chartRepo.PassCredentialsAll || (u.Scheme == impl.repoUrl.Scheme && u.Host == impl.repoUrl.Host)
+}
+
+func GetFromUrlWithRetry(client getter.Getter, absoluteUrl string) (*bytes.Buffer, error) {
+	var (
+		errInGetUrl error
+		response    *bytes.Buffer
+		retries     = 3
+	)
+	for retries > 0 {
+		response, errInGetUrl = client.Get(absoluteUrl)
 		if errInGetUrl != nil {
 			retries -= 1
 			time.Sleep(1 * time.Second)
@@ -93,16 +189,24 @@ func GetFromPrivateUrlWithRetry(baseurl string, absoluteUrl string, username str
 			break
 		}
 	}
-
 	return response, errInGetUrl
 }
 
 func IsValidRegistryChartConfiguration(ociRegistry *sql.DockerArtifactStore) bool {
-	if ociRegistry.OCIRegistryConfig == nil ||
-		len(ociRegistry.OCIRegistryConfig) != 1 ||
-		ociRegistry.OCIRegistryConfig[0].RepositoryType != sql.OCI_REGISRTY_REPO_TYPE_CHART ||
-		ociRegistry.OCIRegistryConfig[0].RepositoryAction == sql.STORAGE_ACTION_TYPE_PUSH {
-		return false
+	return len(GetChartPullConfigs(ociRegistry)) > 0
+}
+
+// GetChartPullConfigs returns the configs of the registry to pull charts with, a registry can have several of them
+// like a public and a private project of the same registry
+func GetChartPullConfigs(ociRegistry *sql.DockerArtifactStore) []*sql.OCIRegistryConfig {
+	chartPullConfigs := make([]*sql.OCIRegistryConfig, 0)
+	for _, ociRegistryConfig := range ociRegistry.OCIRegistryConfig {
+		if ociRegistryConfig == nil ||
+			ociRegistryConfig.RepositoryType != sql.OCI_REGISRTY_REPO_TYPE_CHART ||
+			ociRegistryConfig.RepositoryAction == sql.STORAGE_ACTION_TYPE_PUSH {
+			continue
+		}
+		chartPullConfigs = append(chartPullConfigs, ociRegistryConfig)
 	}
-	return true
+	return chartPullConfigs
 }
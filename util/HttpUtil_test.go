@@ -0,0 +1,131 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/devtron-labs/chart-sync/internals/sql"
+)
+
+func TestChartRepoGetter_Redirects(t *testing.T) {
+	var received http.Header
+	target := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	})
+	otherHostServer := httptest.NewServer(target)
+	defer otherHostServer.Close()
+	otherSchemeServer := httptest.NewTLSServer(target)
+	defer otherSchemeServer.Close()
+	var redirectUrl string
+	repoServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirected" {
+			target(w, r)
+			return
+		}
+		http.Redirect(w, r, redirectUrl, http.StatusFound)
+	}))
+	defer repoServer.Close()
+	// localhost is another host than 127.0.0.1 the servers listen on, as far as the http client is concerned
+	otherHostUrl := strings.Replace(otherHostServer.URL, "127.0.0.1", "localhost", 1) + "/redirected"
+
+	authModes := []struct {
+		authMode    sql.AuthMode
+		chartRepo   sql.ChartRepo
+		header      string
+		headerValue string
+	}{
+		{
+			authMode:    sql.AUTH_MODE_USERNAME_PASSWORD,
+			chartRepo:   sql.ChartRepo{Username: "user", Password: "password"},
+			header:      "Authorization",
+			headerValue: "Basic dXNlcjpwYXNzd29yZA==",
+		},
+		{
+			authMode:    sql.AUTH_MODE_ACCESS_TOKEN,
+			chartRepo:   sql.ChartRepo{AccessToken: "token"},
+			header:      "Authorization",
+			headerValue: "Bearer token",
+		},
+		{
+			authMode:    sql.AUTH_MODE_CUSTOM_HEADERS,
+			chartRepo:   sql.ChartRepo{CustomHeaders: `{"X-Api-Key":"key"}`},
+			header:      "X-Api-Key",
+			headerValue: "key",
+		},
+	}
+	redirects := []struct {
+		name               string
+		redirectUrl        string
+		passCredentialsAll bool
+		wantCredentials    bool
+	}{
+		{name: "same host", redirectUrl: repoServer.URL + "/redirected", wantCredentials: true},
+		{name: "another host", redirectUrl: otherHostUrl},
+		{name: "another scheme", redirectUrl: otherSchemeServer.URL + "/redirected"},
+		{name: "same host passing credentials to all", redirectUrl: repoServer.URL + "/redirected", passCredentialsAll: true, wantCredentials: true},
+		{name: "another host passing credentials to all", redirectUrl: otherHostUrl, passCredentialsAll: true, wantCredentials: true},
+		{name: "another scheme passing credentials to all", redirectUrl: otherSchemeServer.URL + "/redirected", passCredentialsAll: true, wantCredentials: true},
+	}
+	for _, authMode := range authModes {
+		for _, redirect := range redirects {
+			t.Run(string(authMode.authMode)+" "+redirect.name, func(t *testing.T) {
+				chartRepo := authMode.chartRepo
+				chartRepo.Url = repoServer.URL
+				chartRepo.AuthMode = authMode.authMode
+				chartRepo.PassCredentialsAll = redirect.passCredentialsAll
+				chartRepo.AllowInsecureConnection = true
+				chartRepoGetter, err := NewChartRepoGetter(&chartRepo, nil)
+				if err != nil {
+					t.Fatalf("NewChartRepoGetter() error = %v", err)
+				}
+				defer chartRepoGetter.Close()
+				redirectUrl = redirect.redirectUrl
+				received = nil
+				if _, err = chartRepoGetter.Get(repoServer.URL + "/index.yaml"); err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				if received == nil {
+					t.Fatal("redirect target was not requested")
+				}
+				got := received.Get(authMode.header)
+				if redirect.wantCredentials && got != authMode.headerValue {
+					t.Errorf("%s = %q, want %q", authMode.header, got, authMode.headerValue)
+				}
+				if !redirect.wantCredentials && len(got) > 0 {
+					t.Errorf("%s = %q, want none", authMode.header, got)
+				}
+			})
+		}
+	}
+}
+
+func TestChartRepoGetter_CanPassCredentials(t *testing.T) {
+	tests := []struct {
+		name               string
+		url                string
+		passCredentialsAll bool
+		want               bool
+	}{
+		{name: "same scheme and host", url: "https://charts.example.com/charts/a-1.0.0.tgz", want: true},
+		{name: "another port", url: "https://charts.example.com:8443/index.yaml"},
+		{name: "another scheme", url: "http://charts.example.com/index.yaml"},
+		{name: "another host", url: "https://cdn.example.com/index.yaml"},
+		{name: "another host passing credentials to all", url: "https://cdn.example.com/index.yaml", passCredentialsAll: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chartRepoGetter, err := NewChartRepoGetter(&sql.ChartRepo{Url: "https://charts.example.com", PassCredentialsAll: tt.passCredentialsAll}, nil)
+			if err != nil {
+				t.Fatalf("NewChartRepoGetter() error = %v", err)
+			}
+			defer chartRepoGetter.Close()
+			u, _ := url.Parse(tt.url)
+			if got := chartRepoGetter.canPassCredentials(u); got != tt.want {
+				t.Errorf("canPassCredentials() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
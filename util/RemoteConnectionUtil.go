@@ -0,0 +1,155 @@
+package util
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"github.com/devtron-labs/common-lib/utils/remoteConnection/bean"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultSSHPort = "22"
+
+// RemoteConnectionTransport is a http transport reaching its targets directly, through a proxy or through an ssh tunnel
+// as configured in a RemoteConnectionConfig. Close must be called once done to release the ssh connection.
+type RemoteConnectionTransport struct {
+	*http.Transport
+	sshClient *ssh.Client
+}
+
+// NewRemoteConnectionTransport returns the transport of the remote connection config. The host key of the ssh server of
+// ssh tunnels is verified with hostKeyCallback, tunnels are refused when it is nil.
+func NewRemoteConnectionTransport(remoteConnectionConfig *sql.RemoteConnectionConfig, tlsConfig *tls.Config, hostKeyCallback ssh.HostKeyCallback) (*RemoteConnectionTransport, error) {
+	transport := &RemoteConnectionTransport{
+		Transport: &http.Transport{
+			DisableCompression: true,
+			Proxy:              http.ProxyFromEnvironment,
+			TLSClientConfig:    tlsConfig,
+		},
+	}
+	if remoteConnectionConfig == nil || remoteConnectionConfig.Id == 0 {
+		return transport, nil
+	}
+	switch bean.RemoteConnectionMethod(remoteConnectionConfig.ConnectionMethod) {
+	case bean.RemoteConnectionMethodProxy:
+		proxyUrl, err := url.Parse(remoteConnectionConfig.ProxyUrl)
+		if err != nil {
+			return nil, errors.Errorf("invalid proxy URL format: %s", remoteConnectionConfig.ProxyUrl)
+		}
+		transport.Proxy = http.ProxyURL(proxyUrl)
+	case bean.RemoteConnectionMethodSSH:
+		sshClient, err := newSSHClient(remoteConnectionConfig, hostKeyCallback)
+		if err != nil {
+			return nil, err
+		}
+		transport.sshClient = sshClient
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return sshClient.Dial(network, addr)
+		}
+	case "", bean.RemoteConnectionMethodDirect:
+	default:
+		return nil, errors.Errorf("unknown connection method %s of remote connection config %d", remoteConnectionConfig.ConnectionMethod, remoteConnectionConfig.Id)
+	}
+	return transport, nil
+}
+
+// WithTLSConfig returns a transport reaching its targets the same way with another tls.Config, like for registries
+// referenced from the index of a chart repo. It can only be used until this transport is closed.
+func (impl *RemoteConnectionTransport) WithTLSConfig(tlsConfig *tls.Config) *http.Transport {
+	transport := impl.Transport.Clone()
+	transport.TLSClientConfig = tlsConfig
+	return transport
+}
+
+func (impl *RemoteConnectionTransport) Close() error {
+	impl.CloseIdleConnections()
+	if impl.sshClient != nil {
+		return impl.sshClient.Close()
+	}
+	return nil
+}
+
+func newSSHClient(remoteConnectionConfig *sql.RemoteConnectionConfig, hostKeyCallback ssh.HostKeyCallback) (*ssh.Client, error) {
+	if hostKeyCallback == nil {
+		return nil, errors.Errorf("no host key configured for ssh server %s, set SSH_KNOWN_HOSTS_FILE or SSH_HOST_KEY_FINGERPRINTS", remoteConnectionConfig.SSHServerAddress)
+	}
+	var authMethods []ssh.AuthMethod
+	if len(remoteConnectionConfig.SSHAuthKey) > 0 {
+		signer, err := ssh.ParsePrivateKey([]byte(remoteConnectionConfig.SSHAuthKey))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid ssh auth key")
+		}
+		authMethods = append(authMethods, ssh.PublicKeys(signer))
+	}
+	if len(remoteConnectionConfig.SSHPassword) > 0 {
+		authMethods = append(authMethods, ssh.Password(remoteConnectionConfig.SSHPassword))
+	}
+	sshServerAddress := remoteConnectionConfig.SSHServerAddress
+	if _, _, err := net.SplitHostPort(sshServerAddress); err != nil {
+		sshServerAddress = net.JoinHostPort(sshServerAddress, defaultSSHPort)
+	}
+	sshClient, err := ssh.Dial("tcp", sshServerAddress, &ssh.ClientConfig{
+		User:            remoteConnectionConfig.SSHUsername,
+		Auth:            authMethods,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         30 * time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error in connecting to ssh server %s: %v", sshServerAddress, err)
+	}
+	return sshClient, nil
+}
+
+// NewSSHHostKeyCallback returns the callback verifying host keys of ssh servers against the keys of a known_hosts file
+// and against fingerprints like bastion.example.com=SHA256:<base64>,[bastion.example.com]:2222=SHA256:<base64>, as host
+// keys of ssh servers are not captured in the remote connection config. Keys marked @revoked in the known_hosts file
+// are refused even when their fingerprint is configured. It returns nil when neither is configured.
+func NewSSHHostKeyCallback(knownHostsFile string, fingerprints string) (ssh.HostKeyCallback, error) {
+	var knownHostsCallback ssh.HostKeyCallback
+	if len(knownHostsFile) > 0 {
+		var err error
+		knownHostsCallback, err = knownhosts.New(knownHostsFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid ssh known hosts file")
+		}
+	}
+	hostFingerprints := make(map[string][]string)
+	for _, hostFingerprint := range strings.Split(fingerprints, ",") {
+		if len(strings.TrimSpace(hostFingerprint)) == 0 {
+			continue
+		}
+		host, fingerprint, found := strings.Cut(strings.TrimSpace(hostFingerprint), "=")
+		if !found || !strings.HasPrefix(fingerprint, "SHA256:") {
+			return nil, errors.Errorf("invalid ssh host key fingerprint %s, expected host=SHA256:<base64>", hostFingerprint)
+		}
+		hostFingerprints[host] = append(hostFingerprints[host], fingerprint)
+	}
+	if knownHostsCallback == nil && len(hostFingerprints) == 0 {
+		return nil, nil
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if knownHostsCallback != nil {
+			err := knownHostsCallback(hostname, remote, key)
+			var revokedError *knownhosts.RevokedError
+			if err == nil || errors.As(err, &revokedError) {
+				return err
+			}
+		}
+		host := knownhosts.Normalize(hostname)
+		for _, fingerprint := range hostFingerprints[host] {
+			if fingerprint == ssh.FingerprintSHA256(key) {
+				return nil
+			}
+		}
+		return errors.Errorf("host key %s of ssh server %s is not known", ssh.FingerprintSHA256(key), host)
+	}, nil
+}
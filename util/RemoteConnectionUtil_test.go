@@ -0,0 +1,133 @@
+package util
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devtron-labs/chart-sync/internals/sql"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newTestPublicKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sshPublicKey
+}
+
+func TestNewSSHHostKeyCallback(t *testing.T) {
+	bastionKey := newTestPublicKey(t)
+	hashedKey := newTestPublicKey(t)
+	portKey := newTestPublicKey(t)
+	wildcardKey := newTestPublicKey(t)
+	revokedKey := newTestPublicKey(t)
+	fingerprintKey := newTestPublicKey(t)
+	knownHosts := strings.Join([]string{
+		knownhosts.Line([]string{"bastion.example.com"}, bastionKey),
+		knownhosts.Line([]string{knownhosts.HashHostname("hashed.example.com")}, hashedKey),
+		knownhosts.Line([]string{"[port.example.com]:2222"}, portKey),
+		knownhosts.Line([]string{"*.wildcard.example.com"}, wildcardKey),
+		"@revoked " + knownhosts.Line([]string{"bastion.example.com"}, revokedKey),
+	}, "\n")
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(knownHostsFile, []byte(knownHosts+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fingerprints := "[fingerprint.example.com]:2222=" + ssh.FingerprintSHA256(fingerprintKey) +
+		",bastion.example.com=" + ssh.FingerprintSHA256(revokedKey)
+	hostKeyCallback, err := NewSSHHostKeyCallback(knownHostsFile, fingerprints)
+	if err != nil {
+		t.Fatalf("NewSSHHostKeyCallback() error = %v", err)
+	}
+	tests := []struct {
+		name     string
+		hostname string
+		key      ssh.PublicKey
+		wantErr  bool
+	}{
+		{name: "plain host", hostname: "bastion.example.com:22", key: bastionKey},
+		{name: "hashed host", hostname: "hashed.example.com:22", key: hashedKey},
+		{name: "host with port", hostname: "port.example.com:2222", key: portKey},
+		{name: "host with another port", hostname: "port.example.com:22", key: portKey, wantErr: true},
+		{name: "wildcard host", hostname: "a.wildcard.example.com:22", key: wildcardKey},
+		{name: "revoked key with fingerprint", hostname: "bastion.example.com:22", key: revokedKey, wantErr: true},
+		{name: "fingerprint with port", hostname: "fingerprint.example.com:2222", key: fingerprintKey},
+		{name: "key of another host", hostname: "hashed.example.com:22", key: bastionKey, wantErr: true},
+		{name: "unknown host", hostname: "unknown.example.com:22", key: bastionKey, wantErr: true},
+	}
+	remote := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 22}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := hostKeyCallback(tt.hostname, remote, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("hostKeyCallback() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewSSHHostKeyCallback_NotConfigured(t *testing.T) {
+	hostKeyCallback, err := NewSSHHostKeyCallback("", "")
+	if err != nil || hostKeyCallback != nil {
+		t.Errorf("NewSSHHostKeyCallback() = %v, %v, want nil", hostKeyCallback != nil, err)
+	}
+	if _, err = NewSSHHostKeyCallback("", "bastion.example.com=MD5:aa"); err == nil {
+		t.Error("NewSSHHostKeyCallback() expected error for a fingerprint which is not SHA256")
+	}
+}
+
+func TestNewRemoteConnectionTransport_ConnectionMethods(t *testing.T) {
+	tests := []struct {
+		name             string
+		connectionMethod string
+		wantErr          bool
+	}{
+		{name: "direct", connectionMethod: "DIRECT"},
+		{name: "no method", connectionMethod: ""},
+		{name: "proxy", connectionMethod: "PROXY"},
+		{name: "unknown", connectionMethod: "VPN", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, err := NewRemoteConnectionTransport(&sql.RemoteConnectionConfig{Id: 1, ConnectionMethod: tt.connectionMethod, ProxyUrl: "http://proxy.example.com:3128"}, nil, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRemoteConnectionTransport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if transport != nil {
+				transport.Close()
+			}
+		})
+	}
+}
+
+func TestRemoteConnectionTransport_WithTLSConfig(t *testing.T) {
+	transport, err := NewRemoteConnectionTransport(&sql.RemoteConnectionConfig{Id: 1, ConnectionMethod: "PROXY", ProxyUrl: "http://proxy.example.com:3128"}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewRemoteConnectionTransport() error = %v", err)
+	}
+	defer transport.Close()
+	tlsConfig := &tls.Config{ServerName: "registry.example.com"}
+	registryTransport := transport.WithTLSConfig(tlsConfig)
+	if registryTransport.TLSClientConfig != tlsConfig || transport.TLSClientConfig == tlsConfig {
+		t.Error("WithTLSConfig() did not only set the tls.Config of the returned transport")
+	}
+	request, _ := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/", nil)
+	proxyUrl, err := registryTransport.Proxy(request)
+	if err != nil || proxyUrl == nil || proxyUrl.Host != "proxy.example.com:3128" {
+		t.Errorf("Proxy() = %v, %v, want the proxy of the remote connection", proxyUrl, err)
+	}
+}
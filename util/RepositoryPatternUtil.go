@@ -0,0 +1,66 @@
+package util
+
+import (
+	"path"
+	"strings"
+)
+
+const repositoryPatternExclusionPrefix = "!"
+
+// SplitRepositoryPatterns splits the entries of an OCI repository list into plain repository names, glob patterns
+// including repositories and glob patterns (prefixed with !) excluding repositories. Entries are trimmed, empty ones
+// are skipped.
+func SplitRepositoryPatterns(repositoryList []string) (repositories, includePatterns, excludePatterns []string) {
+	repositories, includePatterns, excludePatterns = make([]string, 0), make([]string, 0), make([]string, 0)
+	for _, entry := range repositoryList {
+		entry = strings.TrimSpace(entry)
+		if strings.HasPrefix(entry, repositoryPatternExclusionPrefix) {
+			if excludePattern := strings.TrimSpace(strings.TrimPrefix(entry, repositoryPatternExclusionPrefix)); len(excludePattern) > 0 {
+				excludePatterns = append(excludePatterns, excludePattern)
+			}
+		} else if len(entry) == 0 {
+			continue
+		} else if strings.ContainsAny(entry, "*?[") {
+			includePatterns = append(includePatterns, entry)
+		} else {
+			repositories = append(repositories, entry)
+		}
+	}
+	return repositories, includePatterns, excludePatterns
+}
+
+// MatchAnyRepositoryPattern reports whether the repository matches any of the patterns
+func MatchAnyRepositoryPattern(patterns []string, repository string) bool {
+	for _, pattern := range patterns {
+		if MatchRepositoryPattern(pattern, repository) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchRepositoryPattern reports whether the repository matches the pattern. Patterns are matched segment by segment,
+// * matches any sequence of characters within a segment and ** matches any number of segments.
+// e.g. platform/* matches platform/redis but not platform/team/redis, charts/** matches both.
+func MatchRepositoryPattern(pattern, repository string) bool {
+	return matchRepositorySegments(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(strings.Trim(repository, "/"), "/"))
+}
+
+func matchRepositorySegments(patternSegments, segments []string) bool {
+	if len(patternSegments) == 0 {
+		return len(segments) == 0
+	}
+	if patternSegments[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchRepositorySegments(patternSegments[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	matched, err := path.Match(patternSegments[0], segments[0])
+	return err == nil && matched && matchRepositorySegments(patternSegments[1:], segments[1:])
+}
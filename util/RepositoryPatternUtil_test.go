@@ -0,0 +1,67 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitRepositoryPatterns(t *testing.T) {
+	repositories, includePatterns, excludePatterns := SplitRepositoryPatterns([]string{
+		"library/redis", " charts/* ", "  platform/nginx", "! charts/legacy-*", "", "  ", "!", "platform/**",
+	})
+	if want := []string{"library/redis", "platform/nginx"}; !reflect.DeepEqual(repositories, want) {
+		t.Errorf("repositories = %q, want %q", repositories, want)
+	}
+	if want := []string{"charts/*", "platform/**"}; !reflect.DeepEqual(includePatterns, want) {
+		t.Errorf("includePatterns = %q, want %q", includePatterns, want)
+	}
+	if want := []string{"charts/legacy-*"}; !reflect.DeepEqual(excludePatterns, want) {
+		t.Errorf("excludePatterns = %q, want %q", excludePatterns, want)
+	}
+}
+
+func TestMatchRepositoryPattern(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		repository string
+		want       bool
+	}{
+		{pattern: "platform/*", repository: "platform/redis", want: true},
+		{pattern: "platform/*", repository: "platform/team/redis"},
+		{pattern: "platform/*", repository: "platform"},
+		{pattern: "charts/**", repository: "charts/redis", want: true},
+		{pattern: "charts/**", repository: "charts/team/redis", want: true},
+		{pattern: "charts/**", repository: "charts", want: true},
+		{pattern: "charts/**", repository: "other/charts/redis"},
+		{pattern: "charts/**/redis", repository: "charts/a/b/redis", want: true},
+		{pattern: "charts/**/redis", repository: "charts/redis", want: true},
+		{pattern: "charts/**/redis", repository: "charts/a/redis/b"},
+		{pattern: "**/redis", repository: "a/b/redis", want: true},
+		{pattern: "charts/legacy-*", repository: "charts/legacy-redis", want: true},
+		{pattern: "charts/legacy-*", repository: "charts/redis"},
+		{pattern: "/charts/*/", repository: "charts/redis", want: true},
+		{pattern: "charts/[", repository: "charts/["},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.repository, func(t *testing.T) {
+			if got := MatchRepositoryPattern(tt.pattern, tt.repository); got != tt.want {
+				t.Errorf("MatchRepositoryPattern(%s, %s) = %v, want %v", tt.pattern, tt.repository, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchAnyRepositoryPattern_Exclusions(t *testing.T) {
+	_, includePatterns, excludePatterns := SplitRepositoryPatterns([]string{"charts/**", "!charts/legacy-*"})
+	for repository, want := range map[string]bool{
+		"charts/redis":        true,
+		"charts/team/redis":   true,
+		"charts/legacy-redis": false,
+		"platform/redis":      false,
+	} {
+		got := MatchAnyRepositoryPattern(includePatterns, repository) && !MatchAnyRepositoryPattern(excludePatterns, repository)
+		if got != want {
+			t.Errorf("%s included = %v, want %v", repository, got, want)
+		}
+	}
+}
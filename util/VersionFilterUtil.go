@@ -0,0 +1,161 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Masterminds/semver/v3"
+	"golang.org/x/exp/slices"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// VersionFilterConfig is the json stored as version filter of chart repos, OCI registry configs and app stores
+type VersionFilterConfig struct {
+	Include string `json:"include,omitempty"` // regex versions have to match
+	Exclude string `json:"exclude,omitempty"` // regex versions must not match
+	// semver constraint like >=2.0.0, prerelease versions only satisfy constraints having a prerelease themselves.
+	// Versions which are not semver, like tags of CI builds, are dropped when set.
+	Constraint        string `json:"constraint,omitempty"`
+	IncludePrerelease *bool  `json:"includePrerelease,omitempty"` // prerelease versions are included unless set to false
+	// only the latest n semver versions are kept when set, the stored versions which are not among them are deprecated.
+	// Versions which are not semver are dropped when set, as they cannot be ordered.
+	KeepLatest int `json:"keepLatest,omitempty"`
+}
+
+// VersionFilter selects the chart versions to be synced
+type VersionFilter struct {
+	include           *regexp.Regexp
+	exclude           *regexp.Regexp
+	constraint        *semver.Constraints
+	includePrerelease bool
+	keepLatest        int
+}
+
+// NewVersionFilter builds a VersionFilter from the version filter jsons of a chart provider and of a chart, fields set in
+// a later json override the ones set in the earlier jsons. Empty jsons are skipped, a filter built from none of them
+// keeps all versions.
+func NewVersionFilter(versionFilterJsons ...string) (*VersionFilter, error) {
+	config := &VersionFilterConfig{}
+	for _, versionFilterJson := range versionFilterJsons {
+		if len(strings.TrimSpace(versionFilterJson)) == 0 {
+			continue
+		}
+		override := &VersionFilterConfig{}
+		err := json.Unmarshal([]byte(versionFilterJson), override)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version filter %s: %w", versionFilterJson, err)
+		}
+		config.merge(override)
+	}
+	versionFilter := &VersionFilter{
+		includePrerelease: config.IncludePrerelease == nil || *config.IncludePrerelease,
+		keepLatest:        config.KeepLatest,
+	}
+	var err error
+	if len(config.Include) > 0 {
+		versionFilter.include, err = regexp.Compile(config.Include)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include regex of version filter %s: %w", config.Include, err)
+		}
+	}
+	if len(config.Exclude) > 0 {
+		versionFilter.exclude, err = regexp.Compile(config.Exclude)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude regex of version filter %s: %w", config.Exclude, err)
+		}
+	}
+	if len(config.Constraint) > 0 {
+		versionFilter.constraint, err = semver.NewConstraint(config.Constraint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid semver constraint of version filter %s: %w", config.Constraint, err)
+		}
+	}
+	return versionFilter, nil
+}
+
+func (impl *VersionFilterConfig) merge(override *VersionFilterConfig) {
+	if len(override.Include) > 0 {
+		impl.Include = override.Include
+	}
+	if len(override.Exclude) > 0 {
+		impl.Exclude = override.Exclude
+	}
+	if len(override.Constraint) > 0 {
+		impl.Constraint = override.Constraint
+	}
+	if override.IncludePrerelease != nil {
+		impl.IncludePrerelease = override.IncludePrerelease
+	}
+	if override.KeepLatest > 0 {
+		impl.KeepLatest = override.KeepLatest
+	}
+}
+
+// Filter returns the versions passing the filter in their original order. Versions which are not semver, like tags of
+// CI builds, only pass when neither a semver constraint nor keep latest is set. Build metadata may be separated by _
+// instead of +, like helm does in OCI tags.
+func (impl *VersionFilter) Filter(versions []string) []string {
+	matchedVersions, semverVersions := impl.match(versions)
+	return impl.latest(matchedVersions, semverVersions)
+}
+
+// Outdated returns the versions passing the filter apart from keep latest which are not among the latest versions, in
+// their original order. None are outdated unless keep latest is set.
+func (impl *VersionFilter) Outdated(versions []string) []string {
+	matchedVersions, semverVersions := impl.match(versions)
+	latestVersions := impl.latest(slices.Clone(matchedVersions), semverVersions)
+	return slices.DeleteFunc(matchedVersions, func(version string) bool {
+		return slices.Contains(latestVersions, version)
+	})
+}
+
+// match returns the versions passing the filter apart from keep latest along with the parsed semver versions among them
+func (impl *VersionFilter) match(versions []string) ([]string, map[string]*semver.Version) {
+	matchedVersions := make([]string, 0, len(versions))
+	semverVersions := make(map[string]*semver.Version)
+	for _, version := range versions {
+		if impl.include != nil && !impl.include.MatchString(version) {
+			continue
+		}
+		if impl.exclude != nil && impl.exclude.MatchString(version) {
+			continue
+		}
+		// helm replaces + of the build metadata with _ in OCI tags
+		semverVersion, err := semver.NewVersion(strings.ReplaceAll(version, "_", "+"))
+		if err != nil {
+			if impl.constraint == nil && impl.keepLatest == 0 {
+				matchedVersions = append(matchedVersions, version)
+			}
+			continue
+		}
+		if !impl.includePrerelease && len(semverVersion.Prerelease()) > 0 {
+			continue
+		}
+		if impl.constraint != nil && !impl.constraint.Check(semverVersion) {
+			continue
+		}
+		semverVersions[version] = semverVersion
+		matchedVersions = append(matchedVersions, version)
+	}
+	return matchedVersions, semverVersions
+}
+
+// latest removes the versions which are not among the latest keep latest versions, all of which are semver
+func (impl *VersionFilter) latest(matchedVersions []string, semverVersions map[string]*semver.Version) []string {
+	if impl.keepLatest == 0 || len(matchedVersions) <= impl.keepLatest {
+		return matchedVersions
+	}
+	latestVersions := make([]string, len(matchedVersions))
+	copy(latestVersions, matchedVersions)
+	sort.SliceStable(latestVersions, func(i, j int) bool {
+		return semverVersions[latestVersions[i]].GreaterThan(semverVersions[latestVersions[j]])
+	})
+	keptVersions := make(map[string]bool)
+	for _, version := range latestVersions[:impl.keepLatest] {
+		keptVersions[version] = true
+	}
+	return slices.DeleteFunc(matchedVersions, func(version string) bool {
+		return !keptVersions[version]
+	})
+}
@@ -0,0 +1,119 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVersionFilter_Filter(t *testing.T) {
+	versions := []string{"1.9.0", "1.10.0", "2.0.0-rc.1", "2.0.0", "v10.1.0", "1.2.3_build.7", "1.2.3+build.8", "latest", "main-4f2a1c"}
+	tests := []struct {
+		name               string
+		versionFilterJsons []string
+		want               []string
+	}{
+		{
+			name: "no filter keeps all versions",
+			want: versions,
+		},
+		{
+			name:               "include regex",
+			versionFilterJsons: []string{`{"include":"^1\\."}`},
+			want:               []string{"1.9.0", "1.10.0", "1.2.3_build.7", "1.2.3+build.8"},
+		},
+		{
+			name:               "exclude regex",
+			versionFilterJsons: []string{`{"exclude":"^(latest|main-)"}`},
+			want:               []string{"1.9.0", "1.10.0", "2.0.0-rc.1", "2.0.0", "v10.1.0", "1.2.3_build.7", "1.2.3+build.8"},
+		},
+		{
+			name:               "prerelease excluded",
+			versionFilterJsons: []string{`{"includePrerelease":false}`},
+			want:               []string{"1.9.0", "1.10.0", "2.0.0", "v10.1.0", "1.2.3_build.7", "1.2.3+build.8", "latest", "main-4f2a1c"},
+		},
+		{
+			name:               "constraint drops versions which are not semver",
+			versionFilterJsons: []string{`{"constraint":">=1.10.0"}`},
+			want:               []string{"1.10.0", "2.0.0", "v10.1.0"},
+		},
+		{
+			name:               "constraint with prerelease",
+			versionFilterJsons: []string{`{"constraint":">=2.0.0-0"}`},
+			want:               []string{"2.0.0-rc.1", "2.0.0", "v10.1.0"},
+		},
+		{
+			name:               "keep latest orders by semver and keeps the original order",
+			versionFilterJsons: []string{`{"keepLatest":3}`},
+			want:               []string{"2.0.0-rc.1", "2.0.0", "v10.1.0"},
+		},
+		{
+			name:               "keep latest after the prerelease filter",
+			versionFilterJsons: []string{`{"keepLatest":2,"includePrerelease":false}`},
+			want:               []string{"2.0.0", "v10.1.0"},
+		},
+		{
+			name:               "build metadata separated by _ or +",
+			versionFilterJsons: []string{`{"constraint":"~1.2.3"}`},
+			want:               []string{"1.2.3_build.7", "1.2.3+build.8"},
+		},
+		{
+			name:               "chart filter overrides provider filter",
+			versionFilterJsons: []string{`{"include":"^1\\.","keepLatest":1}`, `{"include":"^2\\."}`},
+			want:               []string{"2.0.0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versionFilter, err := NewVersionFilter(tt.versionFilterJsons...)
+			if err != nil {
+				t.Fatalf("NewVersionFilter() error = %v", err)
+			}
+			if got := versionFilter.Filter(versions); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Filter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionFilter_Outdated(t *testing.T) {
+	versions := []string{"1.9.0", "1.10.0", "2.0.0", "latest"}
+	tests := []struct {
+		name              string
+		versionFilterJson string
+		want              []string
+	}{
+		{
+			name: "none outdated without keep latest",
+			want: []string{},
+		},
+		{
+			name:              "versions which are not among the latest",
+			versionFilterJson: `{"keepLatest":2}`,
+			want:              []string{"1.9.0"},
+		},
+		{
+			name:              "versions failing the filter are not outdated",
+			versionFilterJson: `{"keepLatest":1,"exclude":"^1\\.9"}`,
+			want:              []string{"1.10.0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versionFilter, err := NewVersionFilter(tt.versionFilterJson)
+			if err != nil {
+				t.Fatalf("NewVersionFilter() error = %v", err)
+			}
+			if got := versionFilter.Outdated(versions); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Outdated() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewVersionFilter_Invalid(t *testing.T) {
+	for _, versionFilterJson := range []string{`{`, `{"include":"("}`, `{"exclude":"["}`, `{"constraint":">>1"}`} {
+		if _, err := NewVersionFilter(versionFilterJson); err == nil {
+			t.Errorf("NewVersionFilter(%s) expected error", versionFilterJson)
+		}
+	}
+}
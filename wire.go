@@ -7,40 +7,41 @@ import (
 	"github.com/devtron-labs/chart-sync/internals"
 	"github.com/devtron-labs/chart-sync/internals/logger"
 	"github.com/devtron-labs/chart-sync/internals/sql"
+	"github.com/devtron-labs/chart-sync/internals/storage"
 	"github.com/devtron-labs/chart-sync/pkg"
-	"github.com/devtron-labs/common-lib/helmLib/registry"
+	"github.com/devtron-labs/chart-sync/pkg/provider"
+	"github.com/devtron-labs/chart-sync/pkg/registry"
+	"github.com/devtron-labs/chart-sync/pkg/secret"
 	"github.com/google/wire"
 )
 
 func InitializeApp() (*App, error) {
 	wire.Build(
 		NewApp,
+		logger.GetLogConfig,
+		logger.NewLogLevels,
 		logger.NewSugardLogger,
 		sql.GetConfig,
 		internals.ParseConfiguration,
-		sql.NewDbConnection,
-		sql.NewDockerArtifactStoreRepositoryImpl,
-		wire.Bind(new(sql.DockerArtifactStoreRepository), new(*sql.DockerArtifactStoreRepositoryImpl)),
-		sql.NewOCIRegistryConfigRepositoryImpl,
-		wire.Bind(new(sql.OCIRegistryConfigRepository), new(*sql.OCIRegistryConfigRepositoryImpl)),
-		sql.NewChartRepoRepositoryImpl,
-		wire.Bind(new(sql.ChartRepoRepository), new(*sql.ChartRepoRepositoryImpl)),
-		sql.NewAppStoreRepositoryImpl,
-		wire.Bind(new(sql.AppStoreRepository), new(*sql.AppStoreRepositoryImpl)),
-		sql.NewAppStoreApplicationVersionRepositoryImpl,
-		wire.Bind(new(sql.AppStoreApplicationVersionRepository), new(*sql.AppStoreApplicationVersionRepositoryImpl)),
+		storage.NewRepositories,
+		wire.FieldsOf(new(*storage.Repositories), "DB", "ChartRepoRepository", "DockerArtifactStoreRepository",
+			"OCIRegistryConfigRepository", "RemoteConnectionRepository", "AppStoreRepository", "AppStoreApplicationVersionRepository",
+			"ProviderRepository"),
 		pkg.NewHelmRepoManagerImpl,
 		wire.Bind(new(pkg.HelmRepoManager), new(*pkg.HelmRepoManagerImpl)),
 		pkg.NewSyncServiceImpl,
 		wire.Bind(new(pkg.SyncService), new(*pkg.SyncServiceImpl)),
-		registry.NewSettingsFactoryImpl,
-		wire.Bind(new(registry.SettingsFactory), new(*registry.SettingsFactoryImpl)),
+		registry.NewCredentialProviderImpl,
+		wire.Bind(new(registry.CredentialProvider), new(*registry.CredentialProviderImpl)),
 
-		registry.NewDefaultSettingsGetter,
-		wire.Bind(new(registry.DefaultSettingsGetter), new(*registry.DefaultSettingsGetterImpl)),
+		secret.NewEnvelopeSecretSource,
+		secret.NewSecretResolverImpl,
+		wire.Bind(new(secret.SecretResolver), new(*secret.SecretResolverImpl)),
+		secret.NewCredentialEncryptionServiceImpl,
+		wire.Bind(new(secret.CredentialEncryptionService), new(*secret.CredentialEncryptionServiceImpl)),
 
-		//sql.NewRemoteConnectionRepositoryImpl,
-		//wire.Bind(new(sql.RemoteConnectionRepository), new(*sql.RemoteConnectionRepositoryImpl)),
+		provider.NewProviderServiceImpl,
+		wire.Bind(new(provider.ProviderService), new(*provider.ProviderServiceImpl)),
 	)
 	return &App{}, nil
 }
@@ -10,35 +10,58 @@ import (
 	"github.com/devtron-labs/chart-sync/internals"
 	"github.com/devtron-labs/chart-sync/internals/logger"
 	"github.com/devtron-labs/chart-sync/internals/sql"
+	"github.com/devtron-labs/chart-sync/internals/storage"
 	"github.com/devtron-labs/chart-sync/pkg"
-	"github.com/devtron-labs/common-lib/helmLib/registry"
+	"github.com/devtron-labs/chart-sync/pkg/provider"
+	"github.com/devtron-labs/chart-sync/pkg/registry"
+	"github.com/devtron-labs/chart-sync/pkg/secret"
 )
 
 // Injectors from wire.go:
 
 func InitializeApp() (*App, error) {
-	sugaredLogger := logger.NewSugardLogger()
+	logConfig, err := logger.GetLogConfig()
+	if err != nil {
+		return nil, err
+	}
+	logLevels, err := logger.NewLogLevels(logConfig)
+	if err != nil {
+		return nil, err
+	}
+	sugaredLogger, err := logger.NewSugardLogger(logConfig, logLevels)
+	if err != nil {
+		return nil, err
+	}
 	config, err := sql.GetConfig()
 	if err != nil {
 		return nil, err
 	}
-	db, err := sql.NewDbConnection(config, sugaredLogger)
+	configuration, err := internals.ParseConfiguration()
 	if err != nil {
 		return nil, err
 	}
-	chartRepoRepositoryImpl := sql.NewChartRepoRepositoryImpl(db)
+	repositories, err := storage.NewRepositories(sugaredLogger, configuration, config)
+	if err != nil {
+		return nil, err
+	}
+	db := repositories.DB
+	chartRepoRepository := repositories.ChartRepoRepository
 	helmRepoManagerImpl := pkg.NewHelmRepoManagerImpl(sugaredLogger)
-	dockerArtifactStoreRepositoryImpl := sql.NewDockerArtifactStoreRepositoryImpl(db)
-	ociRegistryConfigRepositoryImpl := sql.NewOCIRegistryConfigRepositoryImpl(db)
-	appStoreRepositoryImpl := sql.NewAppStoreRepositoryImpl(sugaredLogger, db)
-	appStoreApplicationVersionRepositoryImpl := sql.NewAppStoreApplicationVersionRepositoryImpl(sugaredLogger, db)
-	configuration, err := internals.ParseConfiguration()
+	dockerArtifactStoreRepository := repositories.DockerArtifactStoreRepository
+	ociRegistryConfigRepository := repositories.OCIRegistryConfigRepository
+	appStoreRepository := repositories.AppStoreRepository
+	appStoreApplicationVersionRepository := repositories.AppStoreApplicationVersionRepository
+	credentialProviderImpl := registry.NewCredentialProviderImpl(sugaredLogger, configuration)
+	remoteConnectionRepository := repositories.RemoteConnectionRepository
+	envelopeSecretSource := secret.NewEnvelopeSecretSource(sugaredLogger, configuration)
+	secretResolverImpl := secret.NewSecretResolverImpl(sugaredLogger, envelopeSecretSource, configuration)
+	providerRepository := repositories.ProviderRepository
+	providerServiceImpl, err := provider.NewProviderServiceImpl(sugaredLogger, configuration, chartRepoRepository, dockerArtifactStoreRepository, providerRepository, secretResolverImpl)
 	if err != nil {
 		return nil, err
 	}
-	defaultSettingsGetterImpl := registry.NewDefaultSettingsGetter(sugaredLogger)
-	settingsFactoryImpl := registry.NewSettingsFactoryImpl(defaultSettingsGetterImpl)
-	syncServiceImpl := pkg.NewSyncServiceImpl(chartRepoRepositoryImpl, sugaredLogger, helmRepoManagerImpl, dockerArtifactStoreRepositoryImpl, ociRegistryConfigRepositoryImpl, appStoreRepositoryImpl, appStoreApplicationVersionRepositoryImpl, configuration, settingsFactoryImpl)
-	app := NewApp(sugaredLogger, db, syncServiceImpl)
+	syncServiceImpl := pkg.NewSyncServiceImpl(chartRepoRepository, sugaredLogger, helmRepoManagerImpl, dockerArtifactStoreRepository, ociRegistryConfigRepository, appStoreRepository, appStoreApplicationVersionRepository, configuration, credentialProviderImpl, remoteConnectionRepository, secretResolverImpl, providerServiceImpl)
+	credentialEncryptionServiceImpl := secret.NewCredentialEncryptionServiceImpl(sugaredLogger, envelopeSecretSource, chartRepoRepository, dockerArtifactStoreRepository, remoteConnectionRepository, providerRepository)
+	app := NewApp(sugaredLogger, db, syncServiceImpl, configuration, credentialEncryptionServiceImpl, logLevels, providerServiceImpl)
 	return app, nil
 }